@@ -4,15 +4,83 @@ import (
 	"context"
 	"encoding/json"
 	"epherra-api/shared"
+	"epherra-api/shared/metrics"
+	"epherra-api/shared/storage"
 	"fmt"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
+// deleteConcurrency bounds how many backend.Delete calls run at once, so a
+// large expired backlog doesn't open thousands of simultaneous connections
+// to the storage backend.
+const deleteConcurrency = 8
+
+// metadataBatchSize is how many expired documents are removed per
+// DeleteMany call, so a single run never builds one huge _id filter.
+const metadataBatchSize = 500
+
+// expiredFile is the subset of shared.FileMetadata the cleanup pass needs,
+// decoded straight off the cursor instead of buffering full documents.
+type expiredFile struct {
+	ID         bson.ObjectID  `bson:"_id"`
+	Token      string         `bson:"token"`
+	StorageKey string         `bson:"storageKey"`
+	Entries    []expiredEntry `bson:"entries"`
+}
+
+// expiredEntry is the subset of shared.FileEntry a multi-file token's
+// cleanup needs: just enough to delete each bundled file's backend
+// object.
+type expiredEntry struct {
+	StorageKey string `bson:"storageKey"`
+}
+
+// storageKeys returns every backend key a file's bytes live under: its
+// own StorageKey for a single-file token, or one per bundled entry for a
+// multi-file token.
+func (f expiredFile) storageKeys() []string {
+	var keys []string
+	if f.StorageKey != "" {
+		keys = append(keys, f.StorageKey)
+	}
+	for _, e := range f.Entries {
+		if e.StorageKey != "" {
+			keys = append(keys, e.StorageKey)
+		}
+	}
+	return keys
+}
+
+// cleanupFailure records a file whose backend object couldn't be deleted,
+// so the metadata document is left in place and retried on the next run
+// instead of silently orphaning the blob.
+type cleanupFailure struct {
+	Token      string    `bson:"token"`
+	StorageKey string    `bson:"storageKey"`
+	Error      string    `bson:"error"`
+	FailedAt   time.Time `bson:"failedAt"`
+}
+
+func cleanupFailuresCollection() (*mongo.Collection, error) {
+	db, err := shared.GetDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.Collection("cleanup_failures"), nil
+}
+
 func Handler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		metrics.HandlerDuration.WithLabelValues("cleanup").Observe(time.Since(start).Seconds())
+	}()
+
 	authHeader := r.Header.Get("Authorization")
 	cronSecret := os.Getenv("CRON_SECRET")
 
@@ -22,42 +90,25 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	collection, bucket, err := shared.GetDB()
+	collection, _, err := shared.GetDB()
 	if err != nil {
 		http.Error(w, "Database connection failed", http.StatusInternalServerError)
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
 	defer cancel()
 
-	cursor, err := collection.Find(ctx, bson.M{"status": "expired"})
-
-	gridfsDeletedCount := 0
-	inlineDeletedCount := 0
-
-	if err == nil {
-		defer cursor.Close(ctx)
-
-		var expiredFiles []shared.FileMetadata
-		if cursor.All(ctx, &expiredFiles) == nil {
-			for _, file := range expiredFiles {
-				if !file.FileID.IsZero() {
-					err := bucket.Delete(ctx, file.FileID)
-					if err == nil {
-						gridfsDeletedCount++
-					}
-				} else if file.FileData != "" {
-					inlineDeletedCount++
-				}
-			}
-		}
+	backend, err := storage.GetBackend(ctx)
+	if err != nil {
+		http.Error(w, "Storage backend unavailable", http.StatusInternalServerError)
+		return
 	}
 
-	result, err := collection.DeleteMany(ctx, bson.M{"status": "expired"})
-	metadataDeletedCount := int64(0)
-	if err == nil {
-		metadataDeletedCount = result.DeletedCount
+	failures, err := cleanupFailuresCollection()
+	if err != nil {
+		http.Error(w, "Database connection failed", http.StatusInternalServerError)
+		return
 	}
 
 	now := time.Now()
@@ -76,22 +127,131 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		"$set": bson.M{"status": "expired"},
 	})
 
+	cursor, err := collection.Find(ctx, bson.M{"status": "expired"})
+	if err != nil {
+		http.Error(w, "Failed to query expired files", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var (
+		mu                  sync.Mutex
+		wg                  sync.WaitGroup
+		sem                 = make(chan struct{}, deleteConcurrency)
+		backendDeletedCount = 0
+		inlineDeletedCount  = 0
+		errorCount          = 0
+		deletableIDs        []bson.ObjectID
+	)
+
+	deleteBatches := func() {
+		for len(deletableIDs) > 0 {
+			n := metadataBatchSize
+			if n > len(deletableIDs) {
+				n = len(deletableIDs)
+			}
+			batch := deletableIDs[:n]
+			deletableIDs = deletableIDs[n:]
+			collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": batch}})
+		}
+	}
+
+	for cursor.Next(ctx) {
+		var file expiredFile
+		if err := cursor.Decode(&file); err != nil {
+			mu.Lock()
+			errorCount++
+			mu.Unlock()
+			continue
+		}
+
+		keys := file.storageKeys()
+		if len(keys) == 0 {
+			mu.Lock()
+			inlineDeletedCount++
+			deletableIDs = append(deletableIDs, file.ID)
+			if len(deletableIDs) >= metadataBatchSize {
+				deleteBatches()
+			}
+			mu.Unlock()
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(file expiredFile, keys []string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			failed := false
+			for _, key := range keys {
+				if err := backend.Delete(ctx, key); err != nil {
+					mu.Lock()
+					errorCount++
+					mu.Unlock()
+					failures.InsertOne(ctx, cleanupFailure{
+						Token:      file.Token,
+						StorageKey: key,
+						Error:      err.Error(),
+						FailedAt:   time.Now(),
+					})
+					failed = true
+				}
+			}
+			if failed {
+				return
+			}
+
+			mu.Lock()
+			backendDeletedCount += len(keys)
+			deletableIDs = append(deletableIDs, file.ID)
+			if len(deletableIDs) >= metadataBatchSize {
+				deleteBatches()
+			}
+			mu.Unlock()
+		}(file, keys)
+	}
+	wg.Wait()
+	if err := cursor.Err(); err != nil {
+		errorCount++
+	}
+
+	mu.Lock()
+	deleteBatches()
+	mu.Unlock()
+
+	activeFiles, _ := collection.CountDocuments(ctx, bson.M{"status": "active"})
+
+	metrics.CleanupDeletedTotal.WithLabelValues("backend").Add(float64(backendDeletedCount))
+	metrics.CleanupDeletedTotal.WithLabelValues("inline").Add(float64(inlineDeletedCount))
+	metrics.CleanupErrorsTotal.Add(float64(errorCount))
+	metrics.ActiveFiles.Set(float64(activeFiles))
+	metrics.CleanupDuration.Observe(time.Since(start).Seconds())
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
 	response := map[string]any{
-		"success":            true,
-		"timestamp":          time.Now().Format(time.RFC3339),
-		"gridfsFilesDeleted": gridfsDeletedCount,
-		"inlineFilesDeleted": inlineDeletedCount,
-		"metadataDeleted":    metadataDeletedCount,
-		"totalFilesDeleted":  gridfsDeletedCount + inlineDeletedCount,
-		"message": fmt.Sprintf("Cleanup complete: %d GridFS files, %d inline files, %d metadata records deleted",
-			gridfsDeletedCount, inlineDeletedCount, metadataDeletedCount),
+		"success":             true,
+		"timestamp":           time.Now().Format(time.RFC3339),
+		"backendFilesDeleted": backendDeletedCount,
+		"inlineFilesDeleted":  inlineDeletedCount,
+		"totalFilesDeleted":   backendDeletedCount + inlineDeletedCount,
+		"errors":              errorCount,
+		"activeFiles":         activeFiles,
+		"durationSeconds":     time.Since(start).Seconds(),
 	}
 
-	fmt.Printf("Cleanup completed at %s: GridFS=%d, Inline=%d, Metadata=%d\n",
-		time.Now().Format(time.RFC3339), gridfsDeletedCount, inlineDeletedCount, metadataDeletedCount)
+	auditLog, _ := json.Marshal(map[string]any{
+		"event":           "cleanup_run",
+		"timestamp":       time.Now().Format(time.RFC3339),
+		"backendDeleted":  backendDeletedCount,
+		"inlineDeleted":   inlineDeletedCount,
+		"errors":          errorCount,
+		"activeFiles":     activeFiles,
+		"durationSeconds": time.Since(start).Seconds(),
+	})
+	fmt.Println(string(auditLog))
 
 	json.NewEncoder(w).Encode(response)
 }
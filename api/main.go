@@ -1,7 +1,6 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
@@ -20,28 +19,18 @@ import (
 )
 
 type FileMetadata struct {
-	Token          string        `bson:"token" json:"token"`
-	Filename       string        `bson:"filename" json:"filename"`
-	FileType       string        `bson:"fileType" json:"fileType"`
-	FileData       string        `bson:"fileData,omitempty" json:"-"`
-	FileID         bson.ObjectID `bson:"fileId" json:"-"`
-	AllowDownloads bool          `bson:"allowDownloads" json:"allowDownloads"`
-	AllowCopying   bool          `bson:"allowCopying" json:"allowCopying"`
-	UploadedAt     time.Time     `bson:"uploadedAt" json:"uploadedAt"`
-	ExpiresAt      time.Time     `bson:"expiresAt" json:"expiresAt"`
-	MaxViews       *int          `bson:"maxViews" json:"maxViews"`
-	CurrentViews   int           `bson:"currentViews" json:"currentViews"`
-	Status         string        `bson:"status" json:"status"`
-}
-
-type UploadRequest struct {
-	Filename       string    `json:"filename"`
-	FileType       string    `json:"fileType"`
-	FileData       string    `json:"fileData"`
-	AllowDownloads bool      `json:"allowDownloads"`
-	AllowCopying   bool      `json:"allowCopying"`
-	MaxViews       *int      `json:"maxViews"`
-	ExpiresAt      time.Time `json:"expiresAt"`
+	Token          string    `bson:"token" json:"token"`
+	Filename       string    `bson:"filename" json:"filename"`
+	FileType       string    `bson:"fileType" json:"fileType"`
+	FileData       string    `bson:"fileData,omitempty" json:"-"`
+	StorageKey     string    `bson:"storageKey,omitempty" json:"-"`
+	AllowDownloads bool      `bson:"allowDownloads" json:"allowDownloads"`
+	AllowCopying   bool      `bson:"allowCopying" json:"allowCopying"`
+	UploadedAt     time.Time `bson:"uploadedAt" json:"uploadedAt"`
+	ExpiresAt      time.Time `bson:"expiresAt" json:"expiresAt"`
+	MaxViews       *int      `bson:"maxViews" json:"maxViews"`
+	CurrentViews   int       `bson:"currentViews" json:"currentViews"`
+	Status         string    `bson:"status" json:"status"`
 }
 
 var (
@@ -71,9 +60,9 @@ func uploadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req UploadRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "Expected multipart/form-data", http.StatusBadRequest)
 		return
 	}
 
@@ -95,59 +84,97 @@ func uploadHandler(w http.ResponseWriter, r *http.Request) {
 
 		// Videos (natively playable)
 		"video/mp4", "video/webm", "video/ogg",
-	}
 
-	if !contains(validTypes, req.FileType) {
-		http.Error(w, "Invalid file type", http.StatusBadRequest)
-		return
-	}
-
-	fileBytes, err := base64.StdEncoding.DecodeString(req.FileData)
-	if err != nil {
-		http.Error(w, "Invalid file data", http.StatusBadRequest)
-		return
+		// Archives (stored and served whole by this server; it has no
+		// list/entry routes)
+		"application/zip", "application/x-tar", "application/gzip",
 	}
 
 	// Check file size (1.5MB threshold)
 	const maxInlineSize = 1.5 * 1024 * 1024 // 1.5MB
 	token := uuid.New().String()
 
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
 	metadata := FileMetadata{
-		Token:          token,
-		Filename:       req.Filename,
-		FileType:       req.FileType,
-		AllowDownloads: req.AllowDownloads,
-		AllowCopying:   req.AllowCopying,
-		UploadedAt:     time.Now(),
-		ExpiresAt:      req.ExpiresAt,
-		MaxViews:       req.MaxViews,
-		CurrentViews:   0,
-		Status:         "active",
+		Token:        token,
+		UploadedAt:   time.Now(),
+		CurrentViews: 0,
+		Status:       "active",
 	}
+	gotFile := false
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, "Malformed multipart body", http.StatusBadRequest)
+			return
+		}
 
-	if len(fileBytes) <= maxInlineSize {
-		metadata.FileData = req.FileData
-	} else {
-		fileID := bson.NewObjectID()
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
 
-		uploadOpts := options.GridFSUpload().SetMetadata(bson.M{"contentType": req.FileType})
-		uploadStream, err := bucket.OpenUploadStreamWithID(ctx, fileID, req.Filename, uploadOpts)
-		if err != nil {
-			http.Error(w, "Failed to create upload stream", http.StatusInternalServerError)
+		metadata.Filename = part.FileName()
+		metadata.FileType = part.Header.Get("Content-Type")
+
+		if !contains(validTypes, metadata.FileType) {
+			part.Close()
+			http.Error(w, "Invalid file type", http.StatusBadRequest)
 			return
 		}
-		defer uploadStream.Close()
 
-		_, err = io.Copy(uploadStream, bytes.NewReader(fileBytes))
-		if err != nil {
-			http.Error(w, "Failed to upload file", http.StatusInternalServerError)
+		// Buffer only up to the inline threshold; anything past it streams
+		// straight into GridFS instead of being held in memory whole.
+		prefix := make([]byte, int(maxInlineSize)+1)
+		n, readErr := io.ReadFull(part, prefix)
+		if readErr != nil && readErr != io.ErrUnexpectedEOF && readErr != io.EOF {
+			part.Close()
+			http.Error(w, "Failed to read upload", http.StatusInternalServerError)
 			return
 		}
 
-		metadata.FileID = fileID
+		if n <= maxInlineSize {
+			metadata.FileData = base64.StdEncoding.EncodeToString(prefix[:n])
+		} else {
+			storageKey := uuid.New().String()
+			uploadOpts := options.GridFSUpload().SetMetadata(bson.M{"contentType": metadata.FileType})
+			uploadStream, err := bucket.OpenUploadStream(ctx, storageKey, uploadOpts)
+			if err != nil {
+				part.Close()
+				http.Error(w, "Failed to create upload stream", http.StatusInternalServerError)
+				return
+			}
+
+			if _, err := uploadStream.Write(prefix[:n]); err != nil {
+				uploadStream.Close()
+				part.Close()
+				http.Error(w, "Failed to upload file", http.StatusInternalServerError)
+				return
+			}
+			if _, err := io.Copy(uploadStream, part); err != nil {
+				uploadStream.Close()
+				part.Close()
+				http.Error(w, "Failed to upload file", http.StatusInternalServerError)
+				return
+			}
+			uploadStream.Close()
+
+			metadata.StorageKey = storageKey
+		}
+
+		part.Close()
+		gotFile = true
+	}
+
+	if !gotFile {
+		http.Error(w, "file part required", http.StatusBadRequest)
+		return
 	}
 
 	_, err = collection.InsertOne(ctx, metadata)
@@ -220,7 +247,7 @@ func viewHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	downloadStream, err := bucket.OpenDownloadStream(ctx, metadata.FileID)
+	downloadStream, err := bucket.OpenDownloadStreamByName(ctx, metadata.StorageKey)
 	if err != nil {
 		http.Error(w, "Failed to retrieve file", http.StatusInternalServerError)
 		return
@@ -0,0 +1,15 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var promHandler = promhttp.Handler()
+
+// Handler serves GET /api/metrics in Prometheus text exposition format,
+// backed by the collectors registered in epherra-api/shared/metrics.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	promHandler.ServeHTTP(w, r)
+}
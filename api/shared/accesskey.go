@@ -0,0 +1,118 @@
+package shared
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"regexp"
+)
+
+// accessKeyCookiePrefix names the signed cookie set after a request
+// proves a token's access key once, so the same browser isn't
+// re-prompted on later views of that token.
+const accessKeyCookiePrefix = "epherra_access_key_"
+
+// AccessKeyCookieName returns the name of the signed unlock cookie for
+// token. It's scoped per token - rather than one shared cookie slot -
+// so a browser juggling several gated tokens doesn't have unlocking one
+// evict another's proof and force a re-prompt.
+func AccessKeyCookieName(token string) string {
+	return accessKeyCookiePrefix + token
+}
+
+// botUserAgent matches the CLI HTTP clients that should get a plain 401
+// instead of an HTML form they have no way to fill in.
+var botUserAgent = regexp.MustCompile(`(?i)curl|wget|libcurl`)
+
+// IsBrowserRequest reports whether r looks like it came from a browser,
+// as opposed to a script or CLI tool hitting the endpoint directly.
+func IsBrowserRequest(r *http.Request) bool {
+	return !botUserAgent.MatchString(r.Header.Get("User-Agent"))
+}
+
+// HashAccessKey hashes a plaintext access key the same way for storage
+// in FileMetadata.AccessKeys and for checking a supplied key against it.
+func HashAccessKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckAccessKey reports whether key matches one of metadata's hashed
+// access keys.
+func CheckAccessKey(metadata FileMetadata, key string) bool {
+	if key == "" {
+		return false
+	}
+	hashed := HashAccessKey(key)
+	for _, k := range metadata.AccessKeys {
+		if hmac.Equal([]byte(k), []byte(hashed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// accessKeyCookieSecret signs the one-time-unlock cookie so a client
+// can't forge one for a token it never supplied a valid key for. It
+// fails closed: with no secret configured, ok is false and callers must
+// not set or trust the cookie, rather than signing (and later verifying)
+// everything against an empty key.
+func accessKeyCookieSecret() (secret []byte, ok bool) {
+	v := os.Getenv("ACCESS_KEY_COOKIE_SECRET")
+	if v == "" {
+		return nil, false
+	}
+	return []byte(v), true
+}
+
+// SignAccessKeyCookie builds the signed cookie value proving token's
+// access key has already been verified once for this browser. ok is
+// false if ACCESS_KEY_COOKIE_SECRET isn't configured, in which case
+// callers must skip setting the cookie rather than issue an unsigned one.
+func SignAccessKeyCookie(token string) (value string, ok bool) {
+	secret, ok := accessKeyCookieSecret()
+	if !ok {
+		return "", false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil)), true
+}
+
+// VerifyAccessKeyCookie reports whether value is a valid signed cookie
+// previously issued by SignAccessKeyCookie for token. It always reports
+// false when ACCESS_KEY_COOKIE_SECRET isn't configured, since no cookie
+// could have been validly signed in that case.
+func VerifyAccessKeyCookie(token, value string) bool {
+	expected, ok := SignAccessKeyCookie(token)
+	if !ok {
+		return false
+	}
+	return hmac.Equal([]byte(value), []byte(expected))
+}
+
+// AccessKeyOK reports whether r already carries proof of token's access
+// key, checked in precedence order: the X-Access-Key header, the
+// access_key query param, then a signed cookie from an earlier
+// successful check.
+func AccessKeyOK(r *http.Request, token string, metadata FileMetadata) bool {
+	if key := r.Header.Get("X-Access-Key"); key != "" {
+		return CheckAccessKey(metadata, key)
+	}
+	if key := r.URL.Query().Get("access_key"); key != "" {
+		return CheckAccessKey(metadata, key)
+	}
+	if c, err := r.Cookie(AccessKeyCookieName(token)); err == nil {
+		return VerifyAccessKeyCookie(token, c.Value)
+	}
+	return false
+}
+
+// AccessKeyAttempted reports whether r supplied a key to check at all,
+// as opposed to simply lacking the unlock cookie on a first visit. The
+// view handler rate-limits failed attempts, not bare visits.
+func AccessKeyAttempted(r *http.Request) bool {
+	return r.Header.Get("X-Access-Key") != "" || r.URL.Query().Get("access_key") != ""
+}
@@ -0,0 +1,192 @@
+package shared
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// ArchiveMimeTypes are the container formats viewHandler treats as
+// multi-file bundles rather than opaque blobs.
+var ArchiveMimeTypes = []string{
+	"application/zip",
+	"application/x-tar",
+	"application/gzip",
+}
+
+// maxArchiveEntrySize bounds how large a single extracted entry may be, so
+// a maliciously crafted archive can't be used to exhaust memory on list or
+// entry extraction.
+const maxArchiveEntrySize = 25 * 1024 * 1024 // 25MB
+
+// ArchiveEntry describes one file inside an uploaded archive.
+type ArchiveEntry struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// IsArchive reports whether mimeType is one of the bundle formats the view
+// handlers expose a /list and /entry/{path} endpoint for.
+func IsArchive(mimeType string) bool {
+	for _, t := range ArchiveMimeTypes {
+		if t == mimeType {
+			return true
+		}
+	}
+	return false
+}
+
+// ListArchiveEntries walks an archive's table of contents without
+// extracting any entry's data.
+func ListArchiveEntries(mimeType string, data []byte) ([]ArchiveEntry, error) {
+	switch mimeType {
+	case "application/zip":
+		return listZipEntries(data)
+	case "application/x-tar":
+		return listTarEntries(bytes.NewReader(data))
+	case "application/gzip":
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("opening gzip stream: %w", err)
+		}
+		defer gz.Close()
+		return listTarEntries(gz)
+	default:
+		return nil, fmt.Errorf("unsupported archive type: %s", mimeType)
+	}
+}
+
+// ExtractArchiveEntry returns the bytes of a single named entry, rejecting
+// zip-slip paths (absolute paths or ones that escape the archive root via
+// "..") and entries over maxArchiveEntrySize.
+func ExtractArchiveEntry(mimeType string, data []byte, entryPath string) ([]byte, error) {
+	cleanPath, err := sanitizeArchivePath(entryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	switch mimeType {
+	case "application/zip":
+		return extractZipEntry(data, cleanPath)
+	case "application/x-tar":
+		return extractTarEntry(bytes.NewReader(data), cleanPath)
+	case "application/gzip":
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("opening gzip stream: %w", err)
+		}
+		defer gz.Close()
+		return extractTarEntry(gz, cleanPath)
+	default:
+		return nil, fmt.Errorf("unsupported archive type: %s", mimeType)
+	}
+}
+
+// sanitizeArchivePath rejects any entry path that could escape the
+// archive root once joined with an extraction directory.
+func sanitizeArchivePath(entryPath string) (string, error) {
+	if entryPath == "" {
+		return "", fmt.Errorf("empty path")
+	}
+	if path.IsAbs(entryPath) {
+		return "", fmt.Errorf("invalid path: %s", entryPath)
+	}
+	cleaned := path.Clean(entryPath)
+	if cleaned == "." || strings.HasPrefix(cleaned, "../") || cleaned == ".." {
+		return "", fmt.Errorf("invalid path: %s", entryPath)
+	}
+	return cleaned, nil
+}
+
+func listZipEntries(data []byte) ([]ArchiveEntry, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("opening zip archive: %w", err)
+	}
+
+	var entries []ArchiveEntry
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if cleanPath, err := sanitizeArchivePath(f.Name); err == nil {
+			entries = append(entries, ArchiveEntry{Path: cleanPath, Size: int64(f.UncompressedSize64)})
+		}
+	}
+	return entries, nil
+}
+
+func extractZipEntry(data []byte, cleanPath string) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("opening zip archive: %w", err)
+	}
+
+	for _, f := range zr.File {
+		entryPath, err := sanitizeArchivePath(f.Name)
+		if err != nil || entryPath != cleanPath {
+			continue
+		}
+		if int64(f.UncompressedSize64) > maxArchiveEntrySize {
+			return nil, fmt.Errorf("entry %s exceeds max size", cleanPath)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("opening entry %s: %w", cleanPath, err)
+		}
+		defer rc.Close()
+		return io.ReadAll(io.LimitReader(rc, maxArchiveEntrySize))
+	}
+	return nil, fmt.Errorf("entry not found: %s", cleanPath)
+}
+
+func listTarEntries(r io.Reader) ([]ArchiveEntry, error) {
+	tr := tar.NewReader(r)
+	var entries []ArchiveEntry
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading tar entry: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if cleanPath, err := sanitizeArchivePath(hdr.Name); err == nil {
+			entries = append(entries, ArchiveEntry{Path: cleanPath, Size: hdr.Size})
+		}
+	}
+	return entries, nil
+}
+
+func extractTarEntry(r io.Reader, cleanPath string) ([]byte, error) {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading tar entry: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		entryPath, err := sanitizeArchivePath(hdr.Name)
+		if err != nil || entryPath != cleanPath {
+			continue
+		}
+		if hdr.Size > maxArchiveEntrySize {
+			return nil, fmt.Errorf("entry %s exceeds max size", cleanPath)
+		}
+		return io.ReadAll(io.LimitReader(tr, maxArchiveEntrySize))
+	}
+	return nil, fmt.Errorf("entry not found: %s", cleanPath)
+}
@@ -0,0 +1,67 @@
+package shared
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// WriteZipBundle streams entries as a zip archive to w, opening each
+// entry's bytes via open (the inline base64 blob or a storage backend
+// stream, same branch every handler that reads a FileMetadata's
+// contents uses) and closing it once its data has been copied.
+func WriteZipBundle(w io.Writer, entries []FileEntry, open func(FileEntry) (io.ReadCloser, error)) error {
+	zw := zip.NewWriter(w)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.Filename, Method: zip.Deflate}
+		hdr.Modified = e.ModTime
+
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return fmt.Errorf("creating zip entry %s: %w", e.Filename, err)
+		}
+
+		rc, err := open(e)
+		if err != nil {
+			return fmt.Errorf("opening %s: %w", e.Filename, err)
+		}
+		_, err = io.Copy(fw, rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("writing zip entry %s: %w", e.Filename, err)
+		}
+	}
+	return zw.Close()
+}
+
+// WriteTarGzBundle streams entries as a gzip-compressed tar archive to w.
+func WriteTarGzBundle(w io.Writer, entries []FileEntry, open func(FileEntry) (io.ReadCloser, error)) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	for _, e := range entries {
+		rc, err := open(e)
+		if err != nil {
+			return fmt.Errorf("opening %s: %w", e.Filename, err)
+		}
+
+		hdr := &tar.Header{Name: e.Filename, Size: e.Size, Mode: 0o644, ModTime: e.ModTime}
+		if err := tw.WriteHeader(hdr); err != nil {
+			rc.Close()
+			return fmt.Errorf("writing tar header for %s: %w", e.Filename, err)
+		}
+
+		_, err = io.Copy(tw, rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("writing tar entry %s: %w", e.Filename, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("closing tar stream: %w", err)
+	}
+	return gz.Close()
+}
@@ -0,0 +1,66 @@
+package converters
+
+import (
+	"context"
+	"time"
+
+	"epherra-api/shared"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// version bumps whenever a converter's output format changes, so stale
+// cache entries from an older build don't get served after a deploy.
+const version = 1
+
+// cacheEntry is one cached conversion, keyed by the file's token and the
+// converter version that produced it.
+type cacheEntry struct {
+	Token       string `bson:"token"`
+	Version     int    `bson:"version"`
+	Data        []byte `bson:"data"`
+	ContentType string `bson:"contentType"`
+	Filename    string `bson:"filename"`
+}
+
+func cacheCollection() (*mongo.Collection, error) {
+	db, err := shared.GetDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.Collection("conversion_cache"), nil
+}
+
+// GetCached returns a previously converted file's output, if one exists
+// for the current converter version.
+func GetCached(ctx context.Context, token string) (data []byte, contentType, filename string, ok bool, err error) {
+	collection, err := cacheCollection()
+	if err != nil {
+		return nil, "", "", false, err
+	}
+
+	var entry cacheEntry
+	err = collection.FindOne(ctx, bson.M{"token": token, "version": version}).Decode(&entry)
+	if err == mongo.ErrNoDocuments {
+		return nil, "", "", false, nil
+	}
+	if err != nil {
+		return nil, "", "", false, err
+	}
+	return entry.Data, entry.ContentType, entry.Filename, true, nil
+}
+
+// SetCached stores a conversion's output so it only runs once per file.
+func SetCached(ctx context.Context, token string, data []byte, contentType, filename string) error {
+	collection, err := cacheCollection()
+	if err != nil {
+		return err
+	}
+
+	entry := cacheEntry{Token: token, Version: version, Data: data, ContentType: contentType, Filename: filename}
+	opts := options.UpdateOne().SetUpsert(true)
+	_, err = collection.UpdateOne(ctx, bson.M{"token": token, "version": version}, bson.M{"$set": entry}, opts)
+	return err
+}
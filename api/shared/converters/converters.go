@@ -0,0 +1,70 @@
+// Package converters turns an uploaded file's raw bytes into something a
+// browser can render inline without a native app, mirroring the special
+// case the view handlers used to hardcode for Jupyter notebooks. Each
+// Converter claims a set of MIME types; the first one that accepts a
+// file's type runs, and its output (plus the content type/filename it
+// wants set on the response) is cached so the conversion only runs once
+// per file.
+package converters
+
+import (
+	"context"
+	"fmt"
+
+	"epherra-api/shared"
+)
+
+// Converter turns one file's bytes into a browser-renderable form.
+type Converter interface {
+	// Accepts reports whether this converter handles mimeType.
+	Accepts(mimeType string) bool
+	// Convert produces the converted bytes along with the content type
+	// and filename the response should use instead of the original's.
+	Convert(ctx context.Context, in []byte, meta shared.FileMetadata) (out []byte, outMime string, outName string, err error)
+}
+
+// registry holds every converter in registration order; the first match
+// wins, so more specific converters should be registered before general
+// fallbacks.
+var registry []Converter
+
+func register(c Converter) {
+	registry = append(registry, c)
+}
+
+func init() {
+	register(ipynbConverter{})
+	register(markdownConverter{})
+	register(csvConverter{})
+	register(officeConverter{})
+}
+
+// Accepted reports whether some registered converter claims mimeType,
+// without running the conversion. Handlers use this to decide whether a
+// file needs to be buffered and transformed at all, or can be streamed
+// straight through.
+func Accepted(mimeType string) bool {
+	for _, c := range registry {
+		if c.Accepts(mimeType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Convert runs the first registered converter that accepts mimeType. It
+// reports ok=false (with no error) when nothing claims the type, so
+// callers know to serve the original bytes unchanged.
+func Convert(ctx context.Context, mimeType string, in []byte, meta shared.FileMetadata) (out []byte, outMime string, outName string, ok bool, err error) {
+	for _, c := range registry {
+		if !c.Accepts(mimeType) {
+			continue
+		}
+		out, outMime, outName, err = c.Convert(ctx, in, meta)
+		if err != nil {
+			return nil, "", "", false, fmt.Errorf("converting %s: %w", mimeType, err)
+		}
+		return out, outMime, outName, true, nil
+	}
+	return nil, "", "", false, nil
+}
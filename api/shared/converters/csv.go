@@ -0,0 +1,49 @@
+package converters
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"html"
+	"path/filepath"
+	"strings"
+
+	"epherra-api/shared"
+)
+
+// csvConverter renders a CSV file as an HTML table so it previews as a
+// readable grid instead of a wall of commas.
+type csvConverter struct{}
+
+func (csvConverter) Accepts(mimeType string) bool {
+	return mimeType == "text/csv"
+}
+
+func (csvConverter) Convert(ctx context.Context, in []byte, meta shared.FileMetadata) ([]byte, string, string, error) {
+	reader := csv.NewReader(bytes.NewReader(in))
+	reader.FieldsPerRecord = -1
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, "", "", fmt.Errorf("parsing csv: %w", err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("<table>\n")
+	for i, row := range rows {
+		cell := "td"
+		if i == 0 {
+			cell = "th"
+		}
+		buf.WriteString("<tr>")
+		for _, value := range row {
+			fmt.Fprintf(&buf, "<%s>%s</%s>", cell, html.EscapeString(value), cell)
+		}
+		buf.WriteString("</tr>\n")
+	}
+	buf.WriteString("</table>\n")
+
+	outName := strings.TrimSuffix(meta.Filename, filepath.Ext(meta.Filename)) + ".html"
+	return buf.Bytes(), "text/html", outName, nil
+}
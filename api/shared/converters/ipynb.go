@@ -0,0 +1,49 @@
+package converters
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"epherra-api/shared"
+)
+
+// ipynbConverter shells out to jupyter nbconvert, the same tool the view
+// handler used to invoke directly.
+type ipynbConverter struct{}
+
+func (ipynbConverter) Accepts(mimeType string) bool {
+	return mimeType == "application/x-ipynb+json"
+}
+
+func (ipynbConverter) Convert(ctx context.Context, in []byte, meta shared.FileMetadata) ([]byte, string, string, error) {
+	convCtx, cancel := context.WithTimeout(ctx, 20*time.Second)
+	defer cancel()
+
+	tempDir, err := os.MkdirTemp("", "ipynb-conversion-")
+	if err != nil {
+		return nil, "", "", fmt.Errorf("creating temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	ipynbPath := filepath.Join(tempDir, "notebook.ipynb")
+	if err := os.WriteFile(ipynbPath, in, 0644); err != nil {
+		return nil, "", "", fmt.Errorf("writing temp notebook: %w", err)
+	}
+
+	cmd := exec.CommandContext(convCtx, "jupyter", "nbconvert", "--to", "html", "--stdout", ipynbPath)
+	output, err := cmd.Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			return nil, "", "", fmt.Errorf("nbconvert failed: %s (stderr: %s)", ee, ee.Stderr)
+		}
+		return nil, "", "", fmt.Errorf("running nbconvert: %w", err)
+	}
+
+	outName := strings.TrimSuffix(meta.Filename, filepath.Ext(meta.Filename)) + ".html"
+	return output, "text/html", outName, nil
+}
@@ -0,0 +1,28 @@
+package converters
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"epherra-api/shared"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/russross/blackfriday/v2"
+)
+
+// markdownConverter renders Markdown to sanitized HTML so it previews the
+// way it would on a code host instead of downloading as plain text.
+type markdownConverter struct{}
+
+func (markdownConverter) Accepts(mimeType string) bool {
+	return mimeType == "text/markdown"
+}
+
+func (markdownConverter) Convert(ctx context.Context, in []byte, meta shared.FileMetadata) ([]byte, string, string, error) {
+	rendered := blackfriday.Run(in)
+	sanitized := bluemonday.UGCPolicy().SanitizeBytes(rendered)
+
+	outName := strings.TrimSuffix(meta.Filename, filepath.Ext(meta.Filename)) + ".html"
+	return sanitized, "text/html", outName, nil
+}
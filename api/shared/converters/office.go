@@ -0,0 +1,89 @@
+package converters
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"epherra-api/shared"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// officeMimeTypes are the document formats officeConverter hands off to
+// libreoffice for rendering.
+var officeMimeTypes = []string{
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document", // docx
+	"application/vnd.oasis.opendocument.text",                                 // odt
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",       // xlsx
+}
+
+// officeConverter shells out to "libreoffice --headless --convert-to html"
+// the same way ipynbConverter shells out to jupyter.
+type officeConverter struct{}
+
+func (officeConverter) Accepts(mimeType string) bool {
+	for _, t := range officeMimeTypes {
+		if t == mimeType {
+			return true
+		}
+	}
+	return false
+}
+
+func (officeConverter) Convert(ctx context.Context, in []byte, meta shared.FileMetadata) ([]byte, string, string, error) {
+	convCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tempDir, err := os.MkdirTemp("", "office-conversion-")
+	if err != nil {
+		return nil, "", "", fmt.Errorf("creating temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	ext := filepath.Ext(meta.Filename)
+	if ext == "" {
+		ext = officeExtension(meta.FileType)
+	}
+	inPath := filepath.Join(tempDir, "document"+ext)
+	if err := os.WriteFile(inPath, in, 0644); err != nil {
+		return nil, "", "", fmt.Errorf("writing temp document: %w", err)
+	}
+
+	cmd := exec.CommandContext(convCtx, "libreoffice", "--headless", "--convert-to", "html", "--outdir", tempDir, inPath)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, "", "", fmt.Errorf("libreoffice conversion failed: %w (output: %s)", err, output)
+	}
+
+	outPath := filepath.Join(tempDir, "document.html")
+	converted, err := os.ReadFile(outPath)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("reading converted output: %w", err)
+	}
+
+	// LibreOffice's HTML export can carry over active content embedded in
+	// the source document (a DOCX/ODT/XLSX is effectively an untrusted
+	// upload), so it gets the same sanitizing pass the Markdown converter
+	// applies before either is served inline.
+	sanitized := bluemonday.UGCPolicy().SanitizeBytes(converted)
+
+	outName := strings.TrimSuffix(meta.Filename, filepath.Ext(meta.Filename)) + ".html"
+	return sanitized, "text/html", outName, nil
+}
+
+func officeExtension(mimeType string) string {
+	switch mimeType {
+	case "application/vnd.openxmlformats-officedocument.wordprocessingml.document":
+		return ".docx"
+	case "application/vnd.oasis.opendocument.text":
+		return ".odt"
+	case "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":
+		return ".xlsx"
+	default:
+		return ""
+	}
+}
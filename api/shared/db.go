@@ -10,28 +10,70 @@ import (
 	"sync"
 	"time"
 
-	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
 type FileMetadata struct {
-	Token          string        `bson:"token" json:"token"`
-	Filename       string        `bson:"filename" json:"filename"`
-	FileType       string        `bson:"fileType" json:"fileType"`
-	FileData       string        `bson:"fileData,omitempty" json:"-"`
-	FileID         bson.ObjectID `bson:"fileId" json:"-"`
-	AllowDownloads bool          `bson:"allowDownloads" json:"allowDownloads"`
-	AllowCopying   bool          `bson:"allowCopying" json:"allowCopying"`
-	UploadedAt     time.Time     `bson:"uploadedAt" json:"uploadedAt"`
-	ExpiresAt      time.Time     `bson:"expiresAt" json:"expiresAt"`
-	MaxViews       *int          `bson:"maxViews" json:"maxViews"`
-	CurrentViews   int           `bson:"currentViews" json:"currentViews"`
-	Status         string        `bson:"status" json:"status"`
-	PasswordHash   string        `bson:"passwordHash" json:"-"`
-	IsEncrypted    bool          `bson:"isEncrypted" json:"isEncrypted"`
+	Token          string      `bson:"token" json:"token"`
+	Filename       string      `bson:"filename" json:"filename"`
+	FileType       string      `bson:"fileType" json:"fileType"`
+	FileData       string      `bson:"fileData,omitempty" json:"-"`
+	StorageKey     string      `bson:"storageKey,omitempty" json:"-"`
+	AllowDownloads bool        `bson:"allowDownloads" json:"allowDownloads"`
+	AllowCopying   bool        `bson:"allowCopying" json:"allowCopying"`
+	UploadedAt     time.Time   `bson:"uploadedAt" json:"uploadedAt"`
+	ExpiresAt      time.Time   `bson:"expiresAt" json:"expiresAt"`
+	MaxViews       *int        `bson:"maxViews" json:"maxViews"`
+	CurrentViews   int         `bson:"currentViews" json:"currentViews"`
+	Status         string      `bson:"status" json:"status"`
+	IsEncrypted    bool        `bson:"isEncrypted" json:"isEncrypted"`
+	KDFParams      string      `bson:"kdfParams,omitempty" json:"-"`
+	Salt           string      `bson:"salt,omitempty" json:"-"`
+	Nonce          string      `bson:"nonce,omitempty" json:"-"`
+	VerifierTag    string      `bson:"verifierTag,omitempty" json:"-"`
+	Entries        []FileEntry `bson:"entries,omitempty" json:"-"`
+
+	// AccessKeyRequired and AccessKeys gate a view on a linx-server-style
+	// access key, layered on top of (and independent from) IsEncrypted's
+	// password prompt. AccessKeys stores shared.HashAccessKey output, never
+	// the plaintext key itself.
+	AccessKeyRequired bool     `bson:"accessKeyRequired,omitempty" json:"-"`
+	AccessKeys        []string `bson:"accessKeys,omitempty" json:"-"`
+
+	// OriginalFilename and OriginalFileType carry a server-decryptable
+	// upload's true name/type, since FileType on the document itself is
+	// the ciphertext's own MIME type (see shared.IsServerDecryptable).
+	// The view handler restores them on a successful decrypt.
+	OriginalFilename string `bson:"originalFilename,omitempty" json:"-"`
+	OriginalFileType string `bson:"originalFileType,omitempty" json:"-"`
+
+	// SHA256 is computed once while the upload streams into the storage
+	// backend, so the metadata endpoint (see api/view.go's writeMetaInfo)
+	// can report a checksum without re-reading the stored object. Left
+	// empty for a bundle token, since there's no single stream to hash.
+	SHA256 string `bson:"sha256,omitempty" json:"-"`
 }
 
+// FileEntry describes one file inside a multi-file upload token. Single-
+// file uploads leave Entries empty and keep using FileMetadata's own
+// Filename/FileType/FileData/StorageKey; Entries is only populated when a
+// token bundles more than one file for archive download (see
+// shared.WriteZipBundle / WriteTarGzBundle).
+type FileEntry struct {
+	Filename   string    `bson:"filename" json:"filename"`
+	FileType   string    `bson:"fileType" json:"fileType"`
+	FileData   string    `bson:"fileData,omitempty" json:"-"`
+	StorageKey string    `bson:"storageKey,omitempty" json:"-"`
+	Size       int64     `bson:"size" json:"size"`
+	SHA256     string    `bson:"sha256,omitempty" json:"-"`
+	ModTime    time.Time `bson:"modTime" json:"modTime"`
+}
+
+// BundleFileType marks a FileMetadata document whose content lives in
+// Entries rather than its own top-level file fields.
+const BundleFileType = "application/x-epherra-bundle"
+
 var (
 	mongoClient     *mongo.Client
 	collection      *mongo.Collection
@@ -89,6 +131,17 @@ func GetDB() (*mongo.Collection, *mongo.GridFSBucket, error) {
 	return collection, bucket, nil
 }
 
+// GetDatabase returns the shared epherra database handle, establishing the
+// connection via GetDB if it hasn't been opened yet. Callers that need
+// collections beyond "files" (e.g. upload sessions) use this instead of
+// reaching into a new client.
+func GetDatabase() (*mongo.Database, error) {
+	if _, _, err := GetDB(); err != nil {
+		return nil, err
+	}
+	return mongoClient.Database("epherra"), nil
+}
+
 func Handler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusTeapot)
 }
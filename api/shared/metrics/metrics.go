@@ -0,0 +1,45 @@
+// Package metrics holds the Prometheus collectors shared across every
+// serverless function so cleanup, upload, and view handlers all report
+// into the same registry, exposed by api/metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// CleanupDeletedTotal counts files the cleanup cron has removed, split
+	// by where their bytes lived ("backend" or "inline").
+	CleanupDeletedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "epherra_cleanup_deleted_total",
+		Help: "Files removed by the cleanup cron, by storage kind.",
+	}, []string{"kind"})
+
+	// CleanupErrorsTotal counts failures deleting a file's backend object
+	// or decoding its metadata during a cleanup run.
+	CleanupErrorsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "epherra_cleanup_errors_total",
+		Help: "Errors encountered while deleting expired files during cleanup.",
+	})
+
+	// ActiveFiles reports how many FileMetadata documents are currently
+	// in "active" status, sampled at the end of each cleanup run.
+	ActiveFiles = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "epherra_active_files",
+		Help: "Number of FileMetadata documents currently in active status.",
+	})
+
+	// CleanupDuration times a full cleanup cron run.
+	CleanupDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "epherra_cleanup_duration_seconds",
+		Help: "Time taken by the cleanup cron to run to completion.",
+	})
+
+	// HandlerDuration times upload/view API handlers, labeled by handler
+	// name, so latency regressions show up per-endpoint.
+	HandlerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "epherra_handler_duration_seconds",
+		Help: "Latency of upload and view API handlers.",
+	}, []string{"handler"})
+)
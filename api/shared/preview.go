@@ -0,0 +1,103 @@
+package shared
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/russross/blackfriday/v2"
+)
+
+// WantsPreview reports whether r is asking for the zero-JS HTML preview
+// page instead of a file's raw bytes: an explicit ?preview=1, or a
+// browser navigating straight to the link rather than a download
+// manager or <img>/<video> tag pulling it in. ?raw=1 always wins over
+// either signal - it's what the preview page's own Download link sends,
+// and a browser following it still carries Accept: text/html.
+func WantsPreview(r *http.Request) bool {
+	if r.URL.Query().Get("raw") == "1" {
+		return false
+	}
+	return r.URL.Query().Get("preview") == "1" || strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// RenderPreview builds a standalone HTML preview page for a text file,
+// mirroring transfer.sh's previewHandler: Markdown is rendered and
+// sanitized, source code is syntax-highlighted by the extension in
+// meta.Filename, and anything else textual is escaped into a plain
+// <pre>. ok is false for non-text types, so the caller falls through to
+// the normal inline/conversion delivery.
+func RenderPreview(meta FileMetadata, content []byte) (out []byte, ok bool, err error) {
+	var body string
+
+	switch {
+	case meta.FileType == "text/markdown":
+		rendered := blackfriday.Run(content)
+		body = string(bluemonday.UGCPolicy().SanitizeBytes(rendered))
+
+	case strings.HasPrefix(meta.FileType, "text/") || meta.FileType == "application/javascript":
+		lexer := lexers.Match(meta.Filename)
+		if lexer == nil {
+			lexer = lexers.Fallback
+		}
+
+		iterator, lexErr := lexer.Tokenise(nil, string(content))
+		if lexErr != nil {
+			return nil, false, fmt.Errorf("tokenising %s: %w", meta.Filename, lexErr)
+		}
+
+		style := styles.Get("github")
+		formatter := chromahtml.New(chromahtml.WithClasses(true))
+
+		var code, css bytes.Buffer
+		if err := formatter.Format(&code, style, iterator); err != nil {
+			return nil, false, fmt.Errorf("highlighting %s: %w", meta.Filename, err)
+		}
+		formatter.WriteCSS(&css, style)
+
+		body = fmt.Sprintf("<style>%s</style>\n%s", css.String(), code.String())
+
+	default:
+		return nil, false, nil
+	}
+
+	return []byte(previewPage(meta, body)), true, nil
+}
+
+// previewPage wraps body, already-rendered HTML, in a minimal standalone
+// page. It surfaces AllowCopying/AllowDownloads the same way the rich
+// frontend viewer does, so the zero-JS fallback respects the same
+// affordances: copying is blocked via CSS/JS when disallowed, and a
+// download link only appears when allowed.
+func previewPage(meta FileMetadata, body string) string {
+	var disableCopy string
+	if !meta.AllowCopying {
+		disableCopy = `
+<style>body { user-select: none; }</style>
+<script>document.addEventListener("copy", function(e) { e.preventDefault(); });</script>`
+	}
+
+	var downloadLink string
+	if meta.AllowDownloads {
+		downloadLink = fmt.Sprintf(`<p><a href="?raw=1" download="%s">Download</a></p>`, html.EscapeString(meta.Filename))
+	}
+
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+%s
+</head>
+<body>
+%s
+%s
+</body>
+</html>`, html.EscapeString(meta.Filename), disableCopy, downloadLink, body)
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kurin/blazer/b2"
+)
+
+// b2Backend stores blobs in a Backblaze B2 bucket, the cheapest of the
+// object-storage options this package supports.
+type b2Backend struct {
+	bucket *b2.Bucket
+}
+
+func newB2Backend(ctx context.Context) (Backend, error) {
+	accountID := os.Getenv("STORAGE_B2_ACCOUNT_ID")
+	appKey := os.Getenv("STORAGE_B2_APP_KEY")
+	bucketName := os.Getenv("STORAGE_B2_BUCKET")
+	if accountID == "" || appKey == "" || bucketName == "" {
+		return nil, fmt.Errorf("STORAGE_B2_ACCOUNT_ID, STORAGE_B2_APP_KEY and STORAGE_B2_BUCKET must be set for STORAGE_BACKEND=b2")
+	}
+
+	client, err := b2.NewClient(ctx, accountID, appKey)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to B2: %w", err)
+	}
+
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		return nil, fmt.Errorf("opening B2 bucket %s: %w", bucketName, err)
+	}
+
+	return &b2Backend{bucket: bucket}, nil
+}
+
+func (b *b2Backend) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	w := b.bucket.Object(key).NewWriter(ctx)
+	w.ContentType = contentType
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return fmt.Errorf("writing %s: %w", key, err)
+	}
+	return w.Close()
+}
+
+func (b *b2Backend) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	obj := b.bucket.Object(key)
+	attrs, err := obj.Attrs(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading attrs for %s: %w", key, err)
+	}
+	return obj.NewReader(ctx), attrs.Size, nil
+}
+
+func (b *b2Backend) Delete(ctx context.Context, key string) error {
+	if err := b.bucket.Object(key).Delete(ctx); err != nil && !b2.IsNotExist(err) {
+		return fmt.Errorf("deleting %s: %w", key, err)
+	}
+	return nil
+}
+
+func (b *b2Backend) Exists(ctx context.Context, key string) (bool, error) {
+	if _, err := b.bucket.Object(key).Attrs(ctx); err != nil {
+		return false, nil
+	}
+	return true, nil
+}
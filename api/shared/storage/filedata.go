@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+
+	"epherra-api/shared"
+)
+
+// ReadFileBytes returns the full contents of a FileMetadata's backing
+// object, whether it's inlined as base64 or stored behind backend.
+// Handlers that need to operate on a whole file (archive listing,
+// conversion, decryption) use this instead of duplicating the
+// inline/backend branch.
+func ReadFileBytes(ctx context.Context, backend Backend, metadata shared.FileMetadata) ([]byte, error) {
+	if metadata.FileData != "" {
+		return base64.StdEncoding.DecodeString(metadata.FileData)
+	}
+
+	rc, _, err := backend.Get(ctx, metadata.StorageKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return io.ReadAll(rc)
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"epherra-api/shared"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// gridFSBackend stores blobs in the same MongoDB GridFS bucket the API
+// used before Backend existed. It's the default, so self-hosters who
+// haven't set STORAGE_BACKEND see no change in behavior.
+type gridFSBackend struct {
+	bucket *mongo.GridFSBucket
+}
+
+func newGridFSBackend(ctx context.Context) (Backend, error) {
+	_, bucket, err := shared.GetDB()
+	if err != nil {
+		return nil, err
+	}
+	return &gridFSBackend{bucket: bucket}, nil
+}
+
+func (g *gridFSBackend) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	uploadOpts := options.GridFSUpload().SetMetadata(bson.M{"contentType": contentType})
+	stream, err := g.bucket.OpenUploadStream(ctx, key, uploadOpts)
+	if err != nil {
+		return fmt.Errorf("opening upload stream for %s: %w", key, err)
+	}
+	defer stream.Close()
+
+	if _, err := io.Copy(stream, r); err != nil {
+		return fmt.Errorf("writing %s: %w", key, err)
+	}
+	return nil
+}
+
+func (g *gridFSBackend) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	stream, err := g.bucket.OpenDownloadStreamByName(ctx, key)
+	if err != nil {
+		return nil, 0, fmt.Errorf("opening download stream for %s: %w", key, err)
+	}
+	return stream, g.length(ctx, key), nil
+}
+
+// length looks up a file's size from its GridFS metadata document. It
+// returns 0 (rather than an error) when the lookup fails, the same
+// "unknown, caller falls back to unsized" contract Backend.Get documents.
+func (g *gridFSBackend) length(ctx context.Context, key string) int64 {
+	cursor, err := g.bucket.Find(ctx, bson.M{"filename": key})
+	if err != nil {
+		return 0
+	}
+	defer cursor.Close(ctx)
+
+	var file struct {
+		Length int64 `bson:"length"`
+	}
+	if !cursor.Next(ctx) || cursor.Decode(&file) != nil {
+		return 0
+	}
+	return file.Length
+}
+
+func (g *gridFSBackend) Delete(ctx context.Context, key string) error {
+	if exists, err := g.Exists(ctx, key); err == nil && !exists {
+		return nil
+	}
+	if err := g.bucket.DeleteByName(ctx, key); err != nil {
+		return fmt.Errorf("deleting %s: %w", key, err)
+	}
+	return nil
+}
+
+func (g *gridFSBackend) Exists(ctx context.Context, key string) (bool, error) {
+	cursor, err := g.bucket.Find(ctx, bson.M{"filename": key})
+	if err != nil {
+		return false, fmt.Errorf("checking %s: %w", key, err)
+	}
+	defer cursor.Close(ctx)
+	return cursor.Next(ctx), nil
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// localBackend stores blobs as plain files under a root directory, for
+// self-hosters who don't want to run Mongo Atlas or an object store.
+type localBackend struct {
+	root string
+}
+
+func newLocalBackend() (Backend, error) {
+	root := os.Getenv("STORAGE_LOCAL_PATH")
+	if root == "" {
+		root = "./data/blobs"
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("creating local storage root %s: %w", root, err)
+	}
+	return &localBackend{root: root}, nil
+}
+
+// resolve maps a storage key to a path under root. The key is cleaned as
+// if rooted at "/", so a leading "/" or ".." segments can't take the path
+// outside root (the same zip-slip concern shared.ExtractArchiveEntry
+// guards against, applied to keys instead of archive entries); only a
+// key that cleans to nothing is rejected.
+func (l *localBackend) resolve(key string) (string, error) {
+	cleaned := filepath.Clean("/" + key)[1:]
+	if cleaned == "" || cleaned == "." {
+		return "", fmt.Errorf("invalid storage key: %s", key)
+	}
+	return filepath.Join(l.root, cleaned), nil
+}
+
+func (l *localBackend) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	path, err := l.resolve(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating directory for %s: %w", key, err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", key, err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return fmt.Errorf("writing %s: %w", key, err)
+	}
+	return nil
+}
+
+func (l *localBackend) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	path, err := l.resolve(key)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("opening %s: %w", key, err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, fmt.Errorf("stat %s: %w", key, err)
+	}
+	return f, info.Size(), nil
+}
+
+func (l *localBackend) Delete(ctx context.Context, key string) error {
+	path, err := l.resolve(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("deleting %s: %w", key, err)
+	}
+	return nil
+}
+
+func (l *localBackend) Exists(ctx context.Context, key string) (bool, error) {
+	path, err := l.resolve(key)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("stat %s: %w", key, err)
+	}
+	return true, nil
+}
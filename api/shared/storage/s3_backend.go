@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// s3Backend stores blobs in an S3-compatible bucket (AWS S3, R2,
+// MinIO, ...), letting ops move large blobs to cheap object storage
+// while metadata stays in Mongo. Configured entirely via STORAGE_S3_*
+// env vars so switching backends doesn't require a code change.
+type s3Backend struct {
+	client *s3.Client
+	bucket string
+}
+
+func newS3Backend(ctx context.Context) (Backend, error) {
+	bucket := os.Getenv("STORAGE_S3_BUCKET")
+	if bucket == "" {
+		return nil, fmt.Errorf("STORAGE_S3_BUCKET must be set for STORAGE_BACKEND=s3")
+	}
+
+	var optFns []func(*config.LoadOptions) error
+	if region := os.Getenv("STORAGE_S3_REGION"); region != "" {
+		optFns = append(optFns, config.WithRegion(region))
+	}
+	if accessKey, secretKey := os.Getenv("STORAGE_S3_ACCESS_KEY"), os.Getenv("STORAGE_S3_SECRET_KEY"); accessKey != "" && secretKey != "" {
+		optFns = append(optFns, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(accessKey, secretKey, ""),
+		))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, optFns...)
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS config: %w", err)
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint := os.Getenv("STORAGE_S3_ENDPOINT"); endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	return &s3Backend{client: client, bucket: bucket}, nil
+}
+
+func (s *s3Backend) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        r,
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("putting %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *s3Backend) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("getting %s: %w", key, err)
+	}
+
+	size := int64(0)
+	if out.ContentLength != nil {
+		size = *out.ContentLength
+	}
+	return out.Body, size, nil
+}
+
+func (s *s3Backend) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("deleting %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *s3Backend) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("checking %s: %w", key, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServeStream streams rc, a size-byte object, to w honoring Range,
+// If-None-Match and If-Modified-Since, and setting Accept-Ranges,
+// Content-Length and ETag. rc need not be seekable: a requested range is
+// satisfied by discarding leading bytes and copying the rest, so it
+// works equally over a bytes.Reader fallback and a one-shot backend
+// download stream. rc is always closed before ServeStream returns.
+//
+// onFullRead, if non-nil, is called exactly once iff the response
+// serves the object from byte 0 - a full (non-range) request or the
+// first range of a fresh download - so callers can count a view without
+// double-counting the later range requests a browser issues to resume
+// or seek within the same file.
+func ServeStream(w http.ResponseWriter, r *http.Request, rc io.ReadCloser, size int64, etag string, modTime time.Time, contentType, filename string, onFullRead func()) error {
+	defer rc.Close()
+
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `inline; filename="`+filename+`"`)
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" && inm == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !modTime.IsZero() {
+		if t, err := http.ParseTime(ims); err == nil && !modTime.Truncate(time.Second).After(t) {
+			w.WriteHeader(http.StatusNotModified)
+			return nil
+		}
+	}
+
+	start, end := int64(0), size-1
+	status := http.StatusOK
+
+	if rng := r.Header.Get("Range"); rng != "" && size > 0 {
+		s, e, ok := parseRange(rng, size)
+		if !ok {
+			w.Header().Set("Content-Range", "bytes */"+strconv.FormatInt(size, 10))
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return nil
+		}
+		start, end = s, e
+		status = http.StatusPartialContent
+		w.Header().Set("Content-Range", "bytes "+strconv.FormatInt(start, 10)+"-"+strconv.FormatInt(end, 10)+"/"+strconv.FormatInt(size, 10))
+	}
+
+	if size > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(end-start+1, 10))
+	}
+
+	if r.Method == http.MethodHead {
+		w.WriteHeader(status)
+		return nil
+	}
+
+	if start > 0 {
+		if _, err := io.CopyN(io.Discard, rc, start); err != nil {
+			return err
+		}
+	}
+
+	w.WriteHeader(status)
+	if onFullRead != nil && start == 0 {
+		onFullRead()
+	}
+
+	// size <= 0 means the backend couldn't report a length cheaply (see
+	// Backend.Get): there's no end to bound a CopyN at, so copy
+	// until the source is exhausted instead of the 0 bytes end-start+1
+	// would come out to.
+	if size <= 0 {
+		_, err := io.Copy(w, rc)
+		return err
+	}
+
+	_, err := io.CopyN(w, rc, end-start+1)
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// parseRange parses a single-range "bytes=start-end" Range header value
+// against size, the object's total length. Multi-range requests aren't
+// split into multipart/byteranges; only the first range is honored,
+// which every browser range request in practice sends anyway.
+func parseRange(header string, size int64) (start, end int64, ok bool) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(header, prefix) {
+		return 0, 0, false
+	}
+
+	spec := strings.SplitN(strings.TrimPrefix(header, prefix), ",", 2)[0]
+	parts := strings.SplitN(spec, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+
+	if parts[0] == "" {
+		suffix, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || suffix <= 0 {
+			return 0, 0, false
+		}
+		if suffix > size {
+			suffix = size
+		}
+		return size - suffix, size - 1, true
+	}
+
+	start, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false
+	}
+
+	if parts[1] == "" {
+		return start, size - 1, true
+	}
+
+	end, err = strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || end < start {
+		return 0, 0, false
+	}
+	if end >= size {
+		end = size - 1
+	}
+	return start, end, true
+}
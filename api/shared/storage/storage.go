@@ -0,0 +1,75 @@
+// Package storage abstracts the blob store that upload/view/cleanup
+// handlers read and write file contents through, so the Mongo metadata
+// collection doesn't have to double as the blob store. Selection is
+// driven by the STORAGE_BACKEND env var; see GetBackend.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Backend is the pluggable storage abstraction every handler that needs
+// to read or write a file's bytes goes through, instead of reaching for
+// GridFS directly. FileMetadata only keeps a StorageKey; what that key
+// means (a GridFS filename, a path on disk, an object key) is entirely
+// up to the selected Backend.
+type Backend interface {
+	// Put stores r under key, replacing any existing object at that key.
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Get opens a stream for the object at key. Callers must close the
+	// returned ReadCloser. The size is the object's length in bytes
+	// where the backend can report it cheaply, 0 otherwise.
+	Get(ctx context.Context, key string) (rc io.ReadCloser, size int64, err error)
+	// Delete removes the object at key. Deleting a key that doesn't
+	// exist is not an error.
+	Delete(ctx context.Context, key string) error
+	// Exists reports whether an object is stored at key.
+	Exists(ctx context.Context, key string) (bool, error)
+}
+
+var (
+	backend      Backend
+	backendMutex sync.Mutex
+)
+
+// GetBackend returns the process-wide Backend selected by the
+// STORAGE_BACKEND env var ("gridfs", "local", "s3", "b2"; defaults to
+// "gridfs" so existing Atlas-backed deployments need no config change),
+// lazily constructing it on first use.
+func GetBackend(ctx context.Context) (Backend, error) {
+	backendMutex.Lock()
+	defer backendMutex.Unlock()
+
+	if backend != nil {
+		return backend, nil
+	}
+
+	var (
+		b   Backend
+		err error
+	)
+
+	switch kind := os.Getenv("STORAGE_BACKEND"); kind {
+	case "", "gridfs":
+		b, err = newGridFSBackend(ctx)
+	case "local":
+		b, err = newLocalBackend()
+	case "s3":
+		b, err = newS3Backend(ctx)
+	case "b2":
+		b, err = newB2Backend(ctx)
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q", kind)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	backend = b
+	return backend, nil
+}
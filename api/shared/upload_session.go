@@ -0,0 +1,143 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// UploadSession tracks a resumable/chunked upload between the initial
+// POST /api/upload/init call and the POST /api/upload/finalize call that
+// commits it as a FileMetadata document. It keeps the settings the client
+// sent to init so finalize doesn't require the client to resend them.
+type UploadSession struct {
+	Token          string    `bson:"token" json:"token"`
+	Filename       string    `bson:"filename" json:"filename"`
+	FileType       string    `bson:"fileType" json:"fileType"`
+	TotalSize      int64     `bson:"totalSize" json:"totalSize"`
+	Offset         int64     `bson:"offset" json:"offset"`
+	AllowDownloads bool      `bson:"allowDownloads" json:"allowDownloads"`
+	AllowCopying   bool      `bson:"allowCopying" json:"allowCopying"`
+	MaxViews       *int      `bson:"maxViews" json:"maxViews"`
+	ExpiresAt      time.Time `bson:"expiresAt" json:"expiresAt"`
+	KDFParams      string    `bson:"kdfParams,omitempty" json:"-"`
+	Salt           string    `bson:"salt,omitempty" json:"-"`
+	Nonce          string    `bson:"nonce,omitempty" json:"-"`
+	VerifierTag    string    `bson:"verifierTag,omitempty" json:"-"`
+	CreatedAt      time.Time `bson:"createdAt" json:"createdAt"`
+}
+
+// UploadChunk is one PATCH'd byte range of a session, stored as its own
+// object under the active storage.Backend. Finalize concatenates chunks
+// in offset order into the final file stream, then deletes them.
+type UploadChunk struct {
+	Token      string `bson:"token" json:"token"`
+	Offset     int64  `bson:"offset" json:"offset"`
+	Size       int64  `bson:"size" json:"size"`
+	StorageKey string `bson:"storageKey" json:"-"`
+}
+
+func uploadSessionsCollection() (*mongo.Collection, error) {
+	db, err := GetDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.Collection("upload_sessions"), nil
+}
+
+func uploadChunksCollection() (*mongo.Collection, error) {
+	db, err := GetDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.Collection("upload_chunks"), nil
+}
+
+// CreateUploadSession persists a new resumable session.
+func CreateUploadSession(ctx context.Context, session UploadSession) error {
+	collection, err := uploadSessionsCollection()
+	if err != nil {
+		return err
+	}
+	_, err = collection.InsertOne(ctx, session)
+	return err
+}
+
+// GetUploadSession fetches a session by token.
+func GetUploadSession(ctx context.Context, token string) (UploadSession, error) {
+	var session UploadSession
+	collection, err := uploadSessionsCollection()
+	if err != nil {
+		return session, err
+	}
+	err = collection.FindOne(ctx, bson.M{"token": token}).Decode(&session)
+	return session, err
+}
+
+// AdvanceUploadSession moves the session's offset past a successfully
+// appended chunk (the chunk itself is recorded by AppendUploadChunk), so
+// a PATCH starting anywhere else is told where to resume from.
+func AdvanceUploadSession(ctx context.Context, token string, newOffset int64) error {
+	collection, err := uploadSessionsCollection()
+	if err != nil {
+		return err
+	}
+	_, err = collection.UpdateOne(ctx, bson.M{"token": token}, bson.M{"$set": bson.M{"offset": newOffset}})
+	return err
+}
+
+// AppendUploadChunk stores one PATCH'd range under storageKey in the
+// active storage.Backend and records it against the session so finalize
+// can stitch the ranges back together in order.
+func AppendUploadChunk(ctx context.Context, token string, offset int64, size int64, storageKey string) error {
+	collection, err := uploadChunksCollection()
+	if err != nil {
+		return err
+	}
+	_, err = collection.InsertOne(ctx, UploadChunk{Token: token, Offset: offset, Size: size, StorageKey: storageKey})
+	return err
+}
+
+// ListUploadChunks returns every chunk recorded for a session, ordered by
+// offset so they can be streamed into the final object in sequence.
+func ListUploadChunks(ctx context.Context, token string) ([]UploadChunk, error) {
+	collection, err := uploadChunksCollection()
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := collection.Find(ctx, bson.M{"token": token}, options.Find().SetSort(bson.M{"offset": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var chunks []UploadChunk
+	if err := cursor.All(ctx, &chunks); err != nil {
+		return nil, err
+	}
+	return chunks, nil
+}
+
+// DeleteUploadSession removes a session and its chunk records once
+// finalize has committed (or abandoned) the upload. The backend objects
+// backing the chunks are deleted separately by the caller, which already
+// holds a storage.Backend handle.
+func DeleteUploadSession(ctx context.Context, token string) error {
+	sessions, err := uploadSessionsCollection()
+	if err != nil {
+		return err
+	}
+	chunks, err := uploadChunksCollection()
+	if err != nil {
+		return err
+	}
+	if _, err := chunks.DeleteMany(ctx, bson.M{"token": token}); err != nil {
+		return fmt.Errorf("deleting chunk records: %w", err)
+	}
+	_, err = sessions.DeleteOne(ctx, bson.M{"token": token})
+	return err
+}
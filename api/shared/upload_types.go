@@ -0,0 +1,40 @@
+package shared
+
+// ValidUploadTypes are the MIME types either upload path - the direct
+// multipart handler and the resumable chunked one - will accept. Kept
+// in one place so the two handlers can't drift out of sync on which
+// types are allowed.
+var ValidUploadTypes = []string{
+	"application/pdf",
+	"application/x-ipynb+json",
+	// Text & markup
+	"text/plain", "text/markdown", "text/html", "text/css", "text/x-latex", "text/csv",
+	// Programming languages
+	"text/javascript", "application/javascript", "text/x-jsx", "text/x-tsx",
+	"text/x-python", "text/x-csrc", "text/x-c++src", "text/x-java-source",
+	"text/x-go", "text/x-ruby", "text/x-php", "text/x-shellscript",
+	"text/x-typescript", "text/x-rustsrc", "text/x-r", "text/x-powershell",
+	// Images
+	"image/png", "image/jpeg", "image/gif", "image/webp", "image/svg+xml",
+	// Videos
+	"video/mp4", "video/webm", "video/ogg",
+	// Archives (treated as multi-file bundles, see api/view/[token]/list.go)
+	"application/zip", "application/x-tar", "application/gzip",
+	// Office documents (previewed as HTML, see api/shared/converters)
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"application/vnd.oasis.opendocument.text",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	// Pre-encrypted ciphertext (server-decryptable via X-Decrypt-Password,
+	// see api/shared/decrypt.go)
+	"application/pgp-encrypted", "application/x-age-encryption",
+}
+
+// IsValidUploadType reports whether mimeType is one of ValidUploadTypes.
+func IsValidUploadType(mimeType string) bool {
+	for _, t := range ValidUploadTypes {
+		if t == mimeType {
+			return true
+		}
+	}
+	return false
+}
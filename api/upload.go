@@ -3,29 +3,26 @@ package handler
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"epherra-api/shared"
+	"epherra-api/shared/metrics"
+	"epherra-api/shared/storage"
+	"fmt"
 	"io"
 	"net/http"
-	"slices"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
-	"go.mongodb.org/mongo-driver/v2/bson"
-	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
-type UploadRequest struct {
-	Filename       string    `json:"filename"`
-	FileType       string    `json:"fileType"`
-	FileData       string    `json:"fileData"`
-	AllowDownloads bool      `json:"allowDownloads"`
-	AllowCopying   bool      `json:"allowCopying"`
-	MaxViews       *int      `json:"maxViews"`
-	ExpiresAt      time.Time `json:"expiresAt"`
-	PasswordHash   string    `json:"passwordHash"`
-}
+// maxInlineSize is the cutoff below which a file is stored as base64 inside
+// the metadata document instead of the storage backend, same threshold
+// the rest of the API uses.
+const maxInlineSize = 1.5 * 1024 * 1024 // 1.5MB
 
 func setCORSHeaders(w http.ResponseWriter) {
 	w.Header().Set("Access-Control-Allow-Origin", "https://epherra.vercel.app")
@@ -33,7 +30,59 @@ func setCORSHeaders(w http.ResponseWriter) {
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 }
 
+// streamToStorage writes part to the active storage.Backend without
+// buffering it whole in memory. Files at or below maxInlineSize are
+// instead returned as base64 so they can be embedded directly in the
+// metadata document, matching the existing inline-storage optimization.
+// size is the file's total length, needed upfront for multi-file bundle
+// tokens whose tar headers must declare each entry's size before its
+// body. sha256Hex is computed in the same pass so the metadata endpoint
+// never has to re-read the stored object just to report a checksum.
+func streamToStorage(ctx context.Context, backend storage.Backend, filename, contentType string, part io.Reader) (storageKey string, inlineData string, size int64, sha256Hex string, err error) {
+	prefix := make([]byte, int(maxInlineSize)+1)
+	n, readErr := io.ReadFull(part, prefix)
+	if readErr != nil && readErr != io.ErrUnexpectedEOF && readErr != io.EOF {
+		return "", "", 0, "", readErr
+	}
+
+	if n <= int(maxInlineSize) {
+		sum := sha256.Sum256(prefix[:n])
+		return "", base64.StdEncoding.EncodeToString(prefix[:n]), int64(n), hex.EncodeToString(sum[:]), nil
+	}
+
+	storageKey = uuid.New().String()
+	hasher := sha256.New()
+	hasher.Write(prefix[:n])
+	counted := &countingReader{r: io.MultiReader(bytes.NewReader(prefix[:n]), io.TeeReader(part, hasher))}
+	if err := backend.Put(ctx, storageKey, counted, contentType); err != nil {
+		return "", "", 0, "", err
+	}
+
+	return storageKey, "", counted.n, hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// countingReader tracks how many bytes have passed through Read, so
+// streamToStorage can report a file's total size without buffering it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Handler accepts a multipart/form-data upload and streams the "file" part
+// straight into the storage backend (or inlines it, for small files)
+// instead of requiring the whole payload be base64-encoded in a JSON body
+// first. For files too large or unreliable to send in one request, see
+// the resumable protocol under api/upload/{init,finalize,[token]}.
 func Handler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() { metrics.HandlerDuration.WithLabelValues("upload").Observe(time.Since(start).Seconds()) }()
+
 	setCORSHeaders(w)
 
 	if r.Method == "OPTIONS" {
@@ -46,9 +95,7 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	r.Body = http.MaxBytesReader(w, r.Body, 20*1024*1024)
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	ip := shared.GetClientIP(r)
@@ -61,109 +108,144 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	collection, bucket, err := shared.GetDB()
+	collection, _, err := shared.GetDB()
 	if err != nil {
 		http.Error(w, "Database connection failed", http.StatusInternalServerError)
 		return
 	}
 
-	var req UploadRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		if err.Error() == "http: request body too large" {
-			http.Error(w, "File too large (max 20MB)", http.StatusRequestEntityTooLarge)
-		} else {
-			http.Error(w, "Invalid request", http.StatusBadRequest)
-		}
-		return
-	}
-
-	validTypes := []string{
-		"application/pdf",
-		"application/x-ipynb+json",
-		// Text & markup
-		"text/plain", "text/markdown", "text/html", "text/css", "text/x-latex",
-		// Programming languages
-		"text/javascript", "application/javascript", "text/x-jsx", "text/x-tsx",
-		"text/x-python", "text/x-csrc", "text/x-c++src", "text/x-java-source",
-		"text/x-go", "text/x-ruby", "text/x-php", "text/x-shellscript",
-		"text/x-typescript", "text/x-rustsrc", "text/x-r", "text/x-powershell",
-		// Images
-		"image/png", "image/jpeg", "image/gif", "image/webp", "image/svg+xml",
-		// Videos
-		"video/mp4", "video/webm", "video/ogg",
-	}
-
-	if !slices.Contains(validTypes, req.FileType) {
-		http.Error(w, "Invalid file type", http.StatusBadRequest)
+	backend, err := storage.GetBackend(ctx)
+	if err != nil {
+		http.Error(w, "Storage backend unavailable", http.StatusInternalServerError)
 		return
 	}
 
-	fileBytes, err := base64.StdEncoding.DecodeString(req.FileData)
+	mr, err := r.MultipartReader()
 	if err != nil {
-		http.Error(w, "Invalid file data", http.StatusBadRequest)
+		http.Error(w, "Expected multipart/form-data", http.StatusBadRequest)
 		return
 	}
 
-	const maxInlineSize = 1.5 * 1024 * 1024 // 1.5MB
-	token := uuid.New().String()
-
 	metadata := shared.FileMetadata{
-		Token:          token,
-		Filename:       req.Filename,
-		FileType:       req.FileType,
-		AllowDownloads: req.AllowDownloads,
-		AllowCopying:   req.AllowCopying,
-		UploadedAt:     time.Now(),
-		ExpiresAt:      req.ExpiresAt,
-		MaxViews:       req.MaxViews,
-		CurrentViews:   0,
-		Status:         "active",
+		Token:        uuid.New().String(),
+		UploadedAt:   time.Now(),
+		CurrentViews: 0,
+		Status:       "active",
 	}
+	var entries []shared.FileEntry
 
-	if metadata.ExpiresAt.IsZero() {
-		metadata.ExpiresAt = time.Now().Add(72 * time.Hour)
-	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, "Malformed multipart body", http.StatusBadRequest)
+			return
+		}
 
-	if metadata.MaxViews == nil {
-		defaultMaxViews := 1
-		metadata.MaxViews = &defaultMaxViews
+		switch part.FormName() {
+		case "file":
+			filename := part.FileName()
+			fileType := part.Header.Get("Content-Type")
+
+			if !shared.IsValidUploadType(fileType) {
+				part.Close()
+				http.Error(w, "Invalid file type", http.StatusBadRequest)
+				return
+			}
+
+			storageKey, inlineData, size, sha256Hex, err := streamToStorage(ctx, backend, filename, fileType, part)
+			if err != nil {
+				part.Close()
+				http.Error(w, "Failed to store file", http.StatusInternalServerError)
+				return
+			}
+			entries = append(entries, shared.FileEntry{
+				Filename:   filename,
+				FileType:   fileType,
+				FileData:   inlineData,
+				StorageKey: storageKey,
+				Size:       size,
+				SHA256:     sha256Hex,
+				ModTime:    time.Now(),
+			})
+		case "allowDownloads":
+			metadata.AllowDownloads = readFormBool(part)
+		case "allowCopying":
+			metadata.AllowCopying = readFormBool(part)
+		case "maxViews":
+			if v, err := strconv.Atoi(readFormValue(part)); err == nil {
+				metadata.MaxViews = &v
+			}
+		case "expiresAt":
+			if t, err := time.Parse(time.RFC3339, readFormValue(part)); err == nil {
+				metadata.ExpiresAt = t
+			}
+		case "kdfParams":
+			metadata.KDFParams = readFormValue(part)
+		case "salt":
+			metadata.Salt = readFormValue(part)
+		case "nonce":
+			metadata.Nonce = readFormValue(part)
+		case "verifierTag":
+			if tag := readFormValue(part); tag != "" {
+				metadata.VerifierTag = tag
+				metadata.IsEncrypted = true
+			}
+		case "originalFilename":
+			metadata.OriginalFilename = readFormValue(part)
+		case "originalFileType":
+			metadata.OriginalFileType = readFormValue(part)
+		case "accessKey":
+			if key := readFormValue(part); key != "" {
+				metadata.AccessKeys = append(metadata.AccessKeys, shared.HashAccessKey(key))
+				metadata.AccessKeyRequired = true
+			}
+		}
+		part.Close()
 	}
 
-	if req.PasswordHash != "" {
-		metadata.PasswordHash = req.PasswordHash
-		metadata.IsEncrypted = true
+	if len(entries) == 0 {
+		http.Error(w, "file part required", http.StatusBadRequest)
+		return
 	}
 
-	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	if len(fileBytes) <= maxInlineSize {
-		metadata.FileData = req.FileData
+	if len(entries) == 1 {
+		metadata.Filename = entries[0].Filename
+		metadata.FileType = entries[0].FileType
+		metadata.FileData = entries[0].FileData
+		metadata.StorageKey = entries[0].StorageKey
+		metadata.SHA256 = entries[0].SHA256
 	} else {
-		fileID := bson.NewObjectID()
-		uploadOpts := options.GridFSUpload().SetMetadata(bson.M{"contentType": req.FileType})
-		uploadStream, err := bucket.OpenUploadStreamWithID(ctx, fileID, req.Filename, uploadOpts)
-		if err != nil {
-			http.Error(w, "Failed to create upload stream", http.StatusInternalServerError)
-			return
-		}
-		defer uploadStream.Close()
-
-		_, err = io.Copy(uploadStream, bytes.NewReader(fileBytes))
-		if err != nil {
-			http.Error(w, "Failed to upload file", http.StatusInternalServerError)
-			return
-		}
+		metadata.Entries = entries
+		metadata.Filename = fmt.Sprintf("%d files", len(entries))
+		metadata.FileType = shared.BundleFileType
+	}
 
-		metadata.FileID = fileID
+	if metadata.ExpiresAt.IsZero() {
+		metadata.ExpiresAt = time.Now().Add(72 * time.Hour)
+	}
+	if metadata.MaxViews == nil {
+		defaultMaxViews := 1
+		metadata.MaxViews = &defaultMaxViews
 	}
 
-	_, err = collection.InsertOne(ctx, metadata)
-	if err != nil {
+	if _, err := collection.InsertOne(ctx, metadata); err != nil {
 		http.Error(w, "Failed to save metadata", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"token": token})
+	json.NewEncoder(w).Encode(map[string]string{"token": metadata.Token})
+}
+
+// readFormValue reads a small (<=4KB) non-file multipart field.
+func readFormValue(part io.Reader) string {
+	buf, _ := io.ReadAll(io.LimitReader(part, 4096))
+	return string(buf)
+}
+
+func readFormBool(part io.Reader) bool {
+	return readFormValue(part) == "true"
 }
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"epherra-api/shared"
+	"epherra-api/shared/metrics"
+	"epherra-api/shared/storage"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func setCORSHeaders(w http.ResponseWriter) {
+	w.Header().Set("Access-Control-Allow-Origin", "https://epherra.vercel.app")
+	w.Header().Set("Access-Control-Allow-Methods", "PATCH, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Range")
+}
+
+// countingChunkReader wraps a size-bounded chunk body and tracks how many
+// bytes the backend actually consumed, since storage.Backend.Put doesn't
+// report a byte count itself.
+type countingChunkReader struct {
+	src     io.Reader
+	written int64
+}
+
+func (c *countingChunkReader) Read(p []byte) (int, error) {
+	n, err := c.src.Read(p)
+	c.written += int64(n)
+	return n, err
+}
+
+// Handler accepts PATCH /api/upload/{token} with a Content-Range header
+// (`bytes start-end/total`) and streams the request body into the active
+// storage backend, scoped to that byte range. A chunk must start at the
+// session's current offset; one that doesn't (a retry or resume after a
+// dropped connection) gets a 409 carrying the offset to resume from.
+// Each accepted chunk is recorded independently and stitched together
+// in finalize.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	began := time.Now()
+	defer func() { metrics.HandlerDuration.WithLabelValues("upload_chunk").Observe(time.Since(began).Seconds()) }()
+
+	setCORSHeaders(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "PATCH" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := strings.TrimPrefix(r.URL.Path, "/api/upload/")
+	if token == "" {
+		http.Error(w, "Token required", http.StatusBadRequest)
+		return
+	}
+
+	start, end, total, err := parseContentRange(r.Header.Get("Content-Range"))
+	if err != nil {
+		http.Error(w, "Invalid or missing Content-Range", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	session, err := shared.GetUploadSession(ctx, token)
+	if err != nil {
+		http.Error(w, "Upload session not found or expired", http.StatusNotFound)
+		return
+	}
+
+	if total != session.TotalSize {
+		http.Error(w, "Content-Range total does not match session", http.StatusBadRequest)
+		return
+	}
+	if start != session.Offset {
+		// Client is retrying or resuming; tell it where we actually are.
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]int64{"offset": session.Offset})
+		return
+	}
+
+	backend, err := storage.GetBackend(ctx)
+	if err != nil {
+		http.Error(w, "Storage backend unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	chunkKey := fmt.Sprintf("%s.part%d.%s", token, start, uuid.New().String())
+	chunk := &countingChunkReader{src: io.LimitReader(r.Body, end-start+1)}
+	if err := backend.Put(ctx, chunkKey, chunk, session.FileType); err != nil {
+		http.Error(w, "Failed to write chunk", http.StatusInternalServerError)
+		return
+	}
+	written := chunk.written
+
+	newOffset := start + written
+	if err := shared.AppendUploadChunk(ctx, token, start, written, chunkKey); err != nil {
+		http.Error(w, "Failed to record chunk", http.StatusInternalServerError)
+		return
+	}
+	if err := shared.AdvanceUploadSession(ctx, token, newOffset); err != nil {
+		http.Error(w, "Failed to advance session", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"offset": newOffset})
+}
+
+// parseContentRange parses a "bytes start-end/total" header value.
+func parseContentRange(header string) (start, end, total int64, err error) {
+	header = strings.TrimPrefix(header, "bytes ")
+	parts := strings.SplitN(header, "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, 0, fmt.Errorf("malformed Content-Range")
+	}
+	rangeParts := strings.SplitN(parts[0], "-", 2)
+	if len(rangeParts) != 2 {
+		return 0, 0, 0, fmt.Errorf("malformed Content-Range")
+	}
+	if start, err = strconv.ParseInt(rangeParts[0], 10, 64); err != nil {
+		return 0, 0, 0, err
+	}
+	if end, err = strconv.ParseInt(rangeParts[1], 10, 64); err != nil {
+		return 0, 0, 0, err
+	}
+	if total, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+		return 0, 0, 0, err
+	}
+	return start, end, total, nil
+}
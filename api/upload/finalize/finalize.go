@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"epherra-api/shared"
+	"epherra-api/shared/metrics"
+	"epherra-api/shared/storage"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type finalizeRequest struct {
+	Token string `json:"token"`
+}
+
+// Handler commits a resumable session: POST /api/upload/finalize. It
+// stitches the session's chunks back together in offset order into a
+// single storage-backend object, writes the FileMetadata document the
+// rest of the API expects, and cleans up the session's bookkeeping and
+// temporary chunk objects.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() { metrics.HandlerDuration.WithLabelValues("upload_finalize").Observe(time.Since(start).Seconds()) }()
+
+	setCORSHeaders(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req finalizeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	session, err := shared.GetUploadSession(ctx, req.Token)
+	if err != nil {
+		http.Error(w, "Upload session not found or expired", http.StatusNotFound)
+		return
+	}
+
+	if session.Offset != session.TotalSize {
+		http.Error(w, "Upload incomplete", http.StatusConflict)
+		return
+	}
+
+	chunks, err := shared.ListUploadChunks(ctx, req.Token)
+	if err != nil || len(chunks) == 0 {
+		http.Error(w, "No chunks recorded for this session", http.StatusConflict)
+		return
+	}
+
+	collection, _, err := shared.GetDB()
+	if err != nil {
+		http.Error(w, "Database connection failed", http.StatusInternalServerError)
+		return
+	}
+
+	backend, err := storage.GetBackend(ctx)
+	if err != nil {
+		http.Error(w, "Storage backend unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	chunkReaders := make([]io.Reader, len(chunks))
+	chunkClosers := make([]io.Closer, 0, len(chunks))
+	defer func() {
+		for _, c := range chunkClosers {
+			c.Close()
+		}
+	}()
+
+	for i, chunk := range chunks {
+		rc, _, err := backend.Get(ctx, chunk.StorageKey)
+		if err != nil {
+			http.Error(w, "Failed to read chunk", http.StatusInternalServerError)
+			return
+		}
+		chunkReaders[i] = rc
+		chunkClosers = append(chunkClosers, rc)
+	}
+
+	storageKey := uuid.New().String()
+	hasher := sha256.New()
+	if err := backend.Put(ctx, storageKey, io.TeeReader(io.MultiReader(chunkReaders...), hasher), session.FileType); err != nil {
+		http.Error(w, "Failed to assemble file", http.StatusInternalServerError)
+		return
+	}
+
+	metadata := shared.FileMetadata{
+		Token:          session.Token,
+		Filename:       session.Filename,
+		FileType:       session.FileType,
+		StorageKey:     storageKey,
+		AllowDownloads: session.AllowDownloads,
+		AllowCopying:   session.AllowCopying,
+		UploadedAt:     time.Now(),
+		ExpiresAt:      session.ExpiresAt,
+		MaxViews:       session.MaxViews,
+		CurrentViews:   0,
+		Status:         "active",
+		IsEncrypted:    session.VerifierTag != "",
+		KDFParams:      session.KDFParams,
+		Salt:           session.Salt,
+		Nonce:          session.Nonce,
+		VerifierTag:    session.VerifierTag,
+		SHA256:         hex.EncodeToString(hasher.Sum(nil)),
+	}
+
+	if _, err := collection.InsertOne(ctx, metadata); err != nil {
+		http.Error(w, "Failed to save metadata", http.StatusInternalServerError)
+		return
+	}
+
+	for _, chunk := range chunks {
+		backend.Delete(ctx, chunk.StorageKey)
+	}
+	shared.DeleteUploadSession(ctx, req.Token)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"token": metadata.Token})
+}
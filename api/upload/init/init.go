@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"epherra-api/shared"
+	"epherra-api/shared/metrics"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func setCORSHeaders(w http.ResponseWriter) {
+	w.Header().Set("Access-Control-Allow-Origin", "https://epherra.vercel.app")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+}
+
+type initRequest struct {
+	Filename       string    `json:"filename"`
+	FileType       string    `json:"fileType"`
+	TotalSize      int64     `json:"totalSize"`
+	AllowDownloads bool      `json:"allowDownloads"`
+	AllowCopying   bool      `json:"allowCopying"`
+	MaxViews       *int      `json:"maxViews"`
+	ExpiresAt      time.Time `json:"expiresAt"`
+	KDFParams      string    `json:"kdfParams"`
+	Salt           string    `json:"salt"`
+	Nonce          string    `json:"nonce"`
+	VerifierTag    string    `json:"verifierTag"`
+}
+
+// Handler starts a resumable upload session: POST /api/upload/init. It
+// returns a token and the current byte offset (always 0 for a fresh
+// session) that the client PATCHes chunks against and later finalizes.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() { metrics.HandlerDuration.WithLabelValues("upload_init").Observe(time.Since(start).Seconds()) }()
+
+	setCORSHeaders(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ip := shared.GetClientIP(r)
+	if err := shared.CheckRateLimit(ctx, ip, "upload", 5, 24*time.Hour); err != nil {
+		if err.Error() == "rate limit exceeded" {
+			http.Error(w, "Rate limit exceeded: max 5 uploads per 24 hours", http.StatusTooManyRequests)
+		} else {
+			http.Error(w, "Database error checking rate limit", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	var req initRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if !shared.IsValidUploadType(req.FileType) {
+		http.Error(w, "Invalid file type", http.StatusBadRequest)
+		return
+	}
+
+	if req.ExpiresAt.IsZero() {
+		req.ExpiresAt = time.Now().Add(72 * time.Hour)
+	}
+	if req.MaxViews == nil {
+		defaultMaxViews := 1
+		req.MaxViews = &defaultMaxViews
+	}
+
+	session := shared.UploadSession{
+		Token:          uuid.New().String(),
+		Filename:       req.Filename,
+		FileType:       req.FileType,
+		TotalSize:      req.TotalSize,
+		Offset:         0,
+		AllowDownloads: req.AllowDownloads,
+		AllowCopying:   req.AllowCopying,
+		MaxViews:       req.MaxViews,
+		ExpiresAt:      req.ExpiresAt,
+		KDFParams:      req.KDFParams,
+		Salt:           req.Salt,
+		Nonce:          req.Nonce,
+		VerifierTag:    req.VerifierTag,
+		CreatedAt:      time.Now(),
+	}
+
+	if err := shared.CreateUploadSession(ctx, session); err != nil {
+		http.Error(w, "Failed to create upload session", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"token": session.Token, "offset": session.Offset})
+}
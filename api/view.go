@@ -3,9 +3,16 @@ package handler
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/json"
 	"epherra-api/shared"
+	"epherra-api/shared/converters"
+	"epherra-api/shared/metrics"
+	"epherra-api/shared/storage"
+	"errors"
 	"fmt"
+	"html"
 	"io"
 	"net/http"
 	"time"
@@ -16,10 +23,13 @@ import (
 func setCORSHeaders(w http.ResponseWriter) {
 	w.Header().Set("Access-Control-Allow-Origin", "https://epherra.vercel.app")
 	w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-Password-Hash")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-Verifier-Tag")
 }
 
 func Handler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() { metrics.HandlerDuration.WithLabelValues("view").Observe(time.Since(start).Seconds()) }()
+
 	setCORSHeaders(w)
 
 	if r.Method == "OPTIONS" {
@@ -32,7 +42,7 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	collection, bucket, err := shared.GetDB()
+	collection, _, err := shared.GetDB()
 	if err != nil {
 		http.Error(w, "Database connection failed", http.StatusInternalServerError)
 		return
@@ -81,67 +91,392 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Metadata introspection runs ahead of the password/access-key gates
+	// below: it never reveals file bytes, and for a gated token
+	// writeMetaInfo withholds the filename, size and checksum, leaving only
+	// the flags a client needs to know which gate to satisfy next.
+	if r.Method == "HEAD" || r.URL.Query().Get("meta") == "1" {
+		writeMetaInfo(ctx, w, r, token, metadata)
+		return
+	}
+
 	if metadata.IsEncrypted {
-		providedHash := r.Header.Get("X-Password-Hash")
-		if providedHash == "" || providedHash != metadata.PasswordHash {
+		providedTag := r.Header.Get("X-Verifier-Tag")
+		if providedTag == "" || providedTag != metadata.VerifierTag {
 			http.Error(w, "Password required", http.StatusUnauthorized)
 			return
 		}
 	}
 
-	if r.Method == "HEAD" {
-		w.Header().Set("Content-Type", metadata.FileType)
-		w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, metadata.Filename))
-		w.Header().Set("X-Is-Encrypted", fmt.Sprintf("%t", metadata.IsEncrypted))
-		w.Header().Set("X-Allow-Downloads", fmt.Sprintf("%t", metadata.AllowDownloads))
-		w.Header().Set("X-Allow-Copying", fmt.Sprintf("%t", metadata.AllowCopying))
-		w.WriteHeader(http.StatusOK)
+	if metadata.AccessKeyRequired && !shared.AccessKeyOK(r, token, metadata) {
+		if shared.AccessKeyAttempted(r) {
+			if err := shared.CheckRateLimit(ctx, ip, "access_key", 10, 1*time.Hour); err != nil {
+				if err.Error() == "rate limit exceeded" {
+					http.Error(w, "Too many failed access key attempts", http.StatusTooManyRequests)
+				} else {
+					http.Error(w, "Database error checking rate limit", http.StatusInternalServerError)
+				}
+				return
+			}
+		}
+		w.Header().Set("WWW-Authenticate", "Epherra-Access-Key")
+		if shared.IsBrowserRequest(r) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write(accessKeyPrompt(token))
+		} else {
+			http.Error(w, "Access key required", http.StatusUnauthorized)
+		}
 		return
 	}
+	if metadata.AccessKeyRequired {
+		if cookieValue, ok := shared.SignAccessKeyCookie(token); ok {
+			http.SetCookie(w, &http.Cookie{
+				Name:     shared.AccessKeyCookieName(token),
+				Value:    cookieValue,
+				Path:     "/",
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+	}
 
-	var fileBytes []byte
-	if metadata.FileData != "" {
-		decoded, err := base64.StdEncoding.DecodeString(metadata.FileData)
-		if err != nil {
-			http.Error(w, "Invalid file data", http.StatusInternalServerError)
+	markViewed := func() {
+		update := bson.M{"$inc": bson.M{"currentViews": 1}}
+		if metadata.MaxViews != nil && metadata.CurrentViews+1 >= *metadata.MaxViews {
+			update["$set"] = bson.M{"status": "expired"}
+		}
+		collection.UpdateOne(ctx, bson.M{"token": token}, update)
+	}
+
+	w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate")
+	w.Header().Set("X-Is-Encrypted", fmt.Sprintf("%t", metadata.IsEncrypted))
+	w.Header().Set("X-Allow-Downloads", fmt.Sprintf("%t", metadata.AllowDownloads))
+	w.Header().Set("X-Allow-Copying", fmt.Sprintf("%t", metadata.AllowCopying))
+	if metadata.IsEncrypted {
+		w.Header().Set("X-Encryption", "aes-256-gcm")
+		w.Header().Set("X-KDF-Params", metadata.KDFParams)
+		w.Header().Set("X-Salt", metadata.Salt)
+		w.Header().Set("X-Nonce", metadata.Nonce)
+	}
+
+	if len(metadata.Entries) > 0 {
+		if err := serveBundle(ctx, w, r, token, metadata, markViewed); err != nil {
+			http.Error(w, "Failed to stream archive", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if decryptPassword := r.Header.Get("X-Decrypt-Password"); decryptPassword != "" {
+		if !shared.IsServerDecryptable(metadata.FileType) {
+			http.Error(w, "File is not in a server-decryptable format", http.StatusUnsupportedMediaType)
+			return
+		}
+		serveDecrypted(ctx, w, metadata, []byte(decryptPassword), markViewed)
+		return
+	}
+
+	if !metadata.IsEncrypted && shared.WantsPreview(r) {
+		if page, ok, err := renderPreview(ctx, metadata); err == nil && ok {
+			markViewed()
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write(page)
 			return
 		}
-		fileBytes = decoded
-	} else {
-		downloadStream, err := bucket.OpenDownloadStream(ctx, metadata.FileID)
+	}
+
+	// A previewable type still has to be buffered whole so the converter
+	// can transform it; only the untouched original can be range-served
+	// straight from its source. Encrypted and ?raw=1 requests always
+	// want the original bytes, so they always take the streaming path.
+	needsConversion := !metadata.IsEncrypted && r.URL.Query().Get("raw") != "1" && converters.Accepted(metadata.FileType)
+
+	if !needsConversion {
+		rc, size, err := openSource(ctx, metadata)
 		if err != nil {
-			http.Error(w, "Failed to retrieve file", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		defer downloadStream.Close()
 
-		data, err := io.ReadAll(downloadStream)
+		// Derived from the stored checksum rather than CurrentViews: a
+		// browser's later Range requests while seeking/resuming carry the
+		// ETag from its first response, and markViewed would have already
+		// moved CurrentViews on by then, making every such request a
+		// guaranteed If-None-Match miss.
+		etag := fmt.Sprintf(`"%s"`, metadata.SHA256)
+		if metadata.SHA256 == "" {
+			etag = fmt.Sprintf(`"%x"`, sha256.Sum256([]byte(token)))
+		}
+		if err := storage.ServeStream(w, r, rc, size, etag, metadata.UploadedAt, metadata.FileType, metadata.Filename, markViewed); err != nil {
+			http.Error(w, "Failed to stream file", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	var backend storage.Backend
+	if metadata.FileData == "" {
+		backend, err = storage.GetBackend(ctx)
 		if err != nil {
-			http.Error(w, "Failed to read file from storage", http.StatusInternalServerError)
+			http.Error(w, "Storage backend unavailable", http.StatusInternalServerError)
 			return
 		}
-		fileBytes = data
+	}
+
+	fileBytes, err := storage.ReadFileBytes(ctx, backend, metadata)
+	if err != nil {
+		http.Error(w, "Failed to read file", http.StatusInternalServerError)
+		return
 	}
 
 	finalContentType := metadata.FileType
 	finalFilename := metadata.Filename
 	finalBytes := fileBytes
 
-	update := bson.M{"$inc": bson.M{"currentViews": 1}}
-	if metadata.MaxViews != nil && metadata.CurrentViews+1 >= *metadata.MaxViews {
-		update["$set"] = bson.M{"status": "expired"}
+	if cached, cachedType, cachedName, ok, err := converters.GetCached(ctx, token); err == nil && ok {
+		finalBytes, finalContentType, finalFilename = cached, cachedType, cachedName
+	} else if converted, convertedType, convertedName, ok, err := converters.Convert(ctx, metadata.FileType, fileBytes, metadata); err == nil && ok {
+		finalBytes, finalContentType, finalFilename = converted, convertedType, convertedName
+		converters.SetCached(ctx, token, converted, convertedType, convertedName)
 	}
-	collection.UpdateOne(ctx, bson.M{"token": token}, update)
+
+	markViewed()
 
 	w.Header().Set("Content-Type", finalContentType)
 	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, finalFilename))
-	w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate")
-	w.Header().Set("X-Is-Encrypted", fmt.Sprintf("%t", metadata.IsEncrypted))
-	w.Header().Set("X-Allow-Downloads", fmt.Sprintf("%t", metadata.AllowDownloads))
-	w.Header().Set("X-Allow-Copying", fmt.Sprintf("%t", metadata.AllowCopying))
 
-	if _, err := io.Copy(w, bytes.NewReader(finalBytes)); err != nil {
+	if _, err := w.Write(finalBytes); err != nil {
 		http.Error(w, "Failed to stream file", http.StatusInternalServerError)
 		return
 	}
 }
+
+// openSource opens metadata's backing bytes for streaming: a base64
+// blob wrapped as a no-op ReadCloser, or a stream straight from the
+// storage backend. The returned size is 0 when the backend can't report
+// one cheaply, in which case ServeStream serves the whole body and
+// skips Range support.
+func openSource(ctx context.Context, metadata shared.FileMetadata) (io.ReadCloser, int64, error) {
+	if metadata.FileData != "" {
+		decoded, err := base64.StdEncoding.DecodeString(metadata.FileData)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid file data")
+		}
+		return io.NopCloser(bytes.NewReader(decoded)), int64(len(decoded)), nil
+	}
+
+	backend, err := storage.GetBackend(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("storage backend unavailable")
+	}
+
+	rc, size, err := backend.Get(ctx, metadata.StorageKey)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve file")
+	}
+	return rc, size, nil
+}
+
+// serveBundle streams a multi-file token's entries to w as a single
+// archive, per the ?format=zip|tar.gz query param. markViewed is called
+// once the archive has started streaming, same as the single-file path.
+func serveBundle(ctx context.Context, w http.ResponseWriter, r *http.Request, token string, metadata shared.FileMetadata, markViewed func()) error {
+	format := r.URL.Query().Get("format")
+	if format != "zip" && format != "tar.gz" {
+		http.Error(w, `format=zip or format=tar.gz required for multi-file downloads`, http.StatusBadRequest)
+		return nil
+	}
+
+	var backend storage.Backend
+	for _, e := range metadata.Entries {
+		if e.FileData == "" {
+			var err error
+			backend, err = storage.GetBackend(ctx)
+			if err != nil {
+				return fmt.Errorf("storage backend unavailable: %w", err)
+			}
+			break
+		}
+	}
+
+	open := func(e shared.FileEntry) (io.ReadCloser, error) {
+		if e.FileData != "" {
+			decoded, err := base64.StdEncoding.DecodeString(e.FileData)
+			if err != nil {
+				return nil, err
+			}
+			return io.NopCloser(bytes.NewReader(decoded)), nil
+		}
+		rc, _, err := backend.Get(ctx, e.StorageKey)
+		return rc, err
+	}
+
+	contentType := "application/zip"
+	if format == "tar.gz" {
+		contentType = "application/gzip"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, token, format))
+
+	markViewed()
+
+	if format == "zip" {
+		return shared.WriteZipBundle(w, metadata.Entries, open)
+	}
+	return shared.WriteTarGzBundle(w, metadata.Entries, open)
+}
+
+// serveDecrypted opens metadata's stored ciphertext and streams it back
+// through shared.DecryptingReader, restoring the OriginalFilename/
+// OriginalFileType the upload set aside since FileType/Filename on the
+// document itself describe the ciphertext, not the plaintext. Unlike the
+// raw streaming path this can't offer Range support: the passphrase is
+// only known for this one request, so there's nothing to seek within.
+func serveDecrypted(ctx context.Context, w http.ResponseWriter, metadata shared.FileMetadata, passphrase []byte, markViewed func()) {
+	rc, _, err := openSource(ctx, metadata)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rc.Close()
+
+	plaintext, err := shared.DecryptingReader(metadata.FileType, rc, passphrase)
+	if err != nil {
+		if errors.Is(err, shared.ErrUnsupportedFormat) {
+			http.Error(w, "Stored file is not valid ciphertext for its declared format", http.StatusUnsupportedMediaType)
+		} else {
+			http.Error(w, "Incorrect passphrase", http.StatusUnauthorized)
+		}
+		return
+	}
+
+	filename := metadata.OriginalFilename
+	if filename == "" {
+		filename = metadata.Filename
+	}
+	contentType := metadata.OriginalFileType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	markViewed()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, filename))
+	io.Copy(w, plaintext)
+}
+
+// renderPreview reads metadata's bytes and renders the zero-JS HTML
+// preview page for it, when its type supports one (see
+// shared.RenderPreview). ok is false for anything that doesn't, so the
+// caller falls through to the normal inline/conversion delivery.
+func renderPreview(ctx context.Context, metadata shared.FileMetadata) ([]byte, bool, error) {
+	rc, _, err := openSource(ctx, metadata)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rc.Close()
+
+	content, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, false, err
+	}
+	return shared.RenderPreview(metadata, content)
+}
+
+// accessKeyPrompt renders a minimal HTML form a browser can fill in to
+// retry the request with ?access_key=..., for when WWW-Authenticate
+// alone isn't something a human can act on.
+func accessKeyPrompt(token string) []byte {
+	return []byte(fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Access key required</title></head>
+<body>
+<form method="GET">
+<input type="hidden" name="token" value="%s">
+<label>Access key: <input type="password" name="access_key" autofocus></label>
+<button type="submit">Unlock</button>
+</form>
+</body>
+</html>`, html.EscapeString(token)))
+}
+
+// metaInfo is the JSON shape served by HEAD and ?meta=1 requests: enough
+// for a client to decide whether and how to fetch a token without
+// spending a view or being sent its bytes, similar to transfer.sh's
+// sidecar .metadata object.
+type metaInfo struct {
+	Token             string    `json:"token"`
+	Filename          string    `json:"filename,omitempty"`
+	FileType          string    `json:"fileType"`
+	Size              int64     `json:"size,omitempty"`
+	SHA256            string    `json:"sha256,omitempty"`
+	UploadedAt        time.Time `json:"uploadedAt"`
+	ExpiresAt         time.Time `json:"expiresAt"`
+	MaxViews          *int      `json:"maxViews"`
+	CurrentViews      int       `json:"currentViews"`
+	IsEncrypted       bool      `json:"isEncrypted"`
+	AccessKeyRequired bool      `json:"accessKeyRequired"`
+	AllowDownloads    bool      `json:"allowDownloads"`
+	AllowCopying      bool      `json:"allowCopying"`
+}
+
+// writeMetaInfo serves metaInfo for token/metadata: the JSON body for a
+// GET ?meta=1 request, or just the same ETag/Last-Modified/Content-Type
+// headers for HEAD, since HEAD can't carry a body. SHA256 comes straight
+// from the stored document (see streamToStorage/finalize.go, which hash
+// the upload as it streams in) rather than being recomputed here, so
+// introspecting a token never pulls its bytes back out of the storage
+// backend. Size for a bundle token sums its entries, since there's no
+// single stream to size or hash there.
+//
+// Introspection runs before the password/access-key gates (see the call
+// site), so for a gated token this must not leak anything that gate is
+// meant to protect: Filename, Size and SHA256 are withheld until a
+// request actually supplies the key/passphrase, since the hash alone is
+// enough to confirm a guessed file's contents without ever passing the
+// gate.
+func writeMetaInfo(ctx context.Context, w http.ResponseWriter, r *http.Request, token string, metadata shared.FileMetadata) {
+	gated := metadata.AccessKeyRequired || metadata.IsEncrypted
+
+	info := metaInfo{
+		Token:             token,
+		FileType:          metadata.FileType,
+		UploadedAt:        metadata.UploadedAt,
+		ExpiresAt:         metadata.ExpiresAt,
+		MaxViews:          metadata.MaxViews,
+		CurrentViews:      metadata.CurrentViews,
+		IsEncrypted:       metadata.IsEncrypted,
+		AccessKeyRequired: metadata.AccessKeyRequired,
+		AllowDownloads:    metadata.AllowDownloads,
+		AllowCopying:      metadata.AllowCopying,
+	}
+
+	if !gated {
+		info.Filename = metadata.Filename
+		info.SHA256 = metadata.SHA256
+
+		if len(metadata.Entries) > 0 {
+			for _, e := range metadata.Entries {
+				info.Size += e.Size
+			}
+		} else if rc, size, err := openSource(ctx, metadata); err == nil {
+			info.Size = size
+			rc.Close()
+		}
+	}
+
+	etag := fmt.Sprintf(`"%x"`, sha256.Sum256([]byte(fmt.Sprintf("%s:%d:%s", token, metadata.CurrentViews, metadata.Status))))
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", metadata.UploadedAt.UTC().Format(http.TimeFormat))
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if r.Method == "HEAD" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	json.NewEncoder(w).Encode(info)
+}
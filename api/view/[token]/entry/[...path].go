@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"epherra-api/shared"
+	"epherra-api/shared/metrics"
+	"epherra-api/shared/storage"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func setCORSHeaders(w http.ResponseWriter) {
+	w.Header().Set("Access-Control-Allow-Origin", "https://epherra.vercel.app")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-Verifier-Tag")
+}
+
+// Handler serves GET /api/view/{token}/entry/{path} by streaming a single
+// file out of an archive upload, leaving the rest of the bundle
+// untouched. It enforces the same expiry/view-limit/password gating as
+// viewing the archive itself, and counts against the token's view budget
+// the same way.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() { metrics.HandlerDuration.WithLabelValues("view_entry").Observe(time.Since(start).Seconds()) }()
+
+	setCORSHeaders(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token, entryPath := extractTokenAndPath(r.URL.Path)
+	if token == "" || entryPath == "" {
+		http.Error(w, "Token and entry path required", http.StatusBadRequest)
+		return
+	}
+
+	collection, _, err := shared.GetDB()
+	if err != nil {
+		http.Error(w, "Database connection failed", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	backend, err := storage.GetBackend(ctx)
+	if err != nil {
+		http.Error(w, "Storage backend unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	var metadata shared.FileMetadata
+	if err := collection.FindOne(ctx, bson.M{"token": token}).Decode(&metadata); err != nil {
+		http.Error(w, "File not found or expired", http.StatusNotFound)
+		return
+	}
+
+	if metadata.Status != "active" || time.Now().After(metadata.ExpiresAt) {
+		http.Error(w, "File has expired", http.StatusGone)
+		return
+	}
+
+	if metadata.MaxViews != nil && metadata.CurrentViews >= *metadata.MaxViews {
+		http.Error(w, "View limit reached", http.StatusGone)
+		return
+	}
+
+	if !shared.IsArchive(metadata.FileType) {
+		http.Error(w, "Not an archive", http.StatusBadRequest)
+		return
+	}
+
+	if metadata.IsEncrypted {
+		providedTag := r.Header.Get("X-Verifier-Tag")
+		if providedTag == "" || providedTag != metadata.VerifierTag {
+			http.Error(w, "Password required", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	data, err := storage.ReadFileBytes(ctx, backend, metadata)
+	if err != nil {
+		http.Error(w, "Failed to read archive", http.StatusInternalServerError)
+		return
+	}
+
+	entryBytes, err := shared.ExtractArchiveEntry(metadata.FileType, data, entryPath)
+	if err != nil {
+		http.Error(w, "Entry not found", http.StatusNotFound)
+		return
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(entryPath))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	update := bson.M{"$inc": bson.M{"currentViews": 1}}
+	if metadata.MaxViews != nil && metadata.CurrentViews+1 >= *metadata.MaxViews {
+		update["$set"] = bson.M{"status": "expired"}
+	}
+	collection.UpdateOne(ctx, bson.M{"token": token}, update)
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, filepath.Base(entryPath)))
+	w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate")
+	w.Write(entryBytes)
+}
+
+// extractTokenAndPath pulls {token} and {path} out of
+// /api/view/{token}/entry/{path}, where path may itself contain slashes.
+func extractTokenAndPath(urlPath string) (token, entryPath string) {
+	trimmed := strings.TrimPrefix(urlPath, "/api/view/")
+	parts := strings.SplitN(trimmed, "/entry/", 2)
+	if len(parts) != 2 {
+		return "", ""
+	}
+	return parts[0], parts[1]
+}
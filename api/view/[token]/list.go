@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"epherra-api/shared"
+	"epherra-api/shared/metrics"
+	"epherra-api/shared/storage"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func setCORSHeaders(w http.ResponseWriter) {
+	w.Header().Set("Access-Control-Allow-Origin", "https://epherra.vercel.app")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-Verifier-Tag")
+}
+
+// Handler serves GET /api/view/{token}/list: the table of contents of an
+// archive upload, without extracting any entry or consuming a view. It
+// honors the same expiry/view-limit/password checks as the regular view
+// handler so a list can't be used to bypass them.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() { metrics.HandlerDuration.WithLabelValues("view_list").Observe(time.Since(start).Seconds()) }()
+
+	setCORSHeaders(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := extractToken(r.URL.Path)
+	if token == "" {
+		http.Error(w, "Token required", http.StatusBadRequest)
+		return
+	}
+
+	collection, _, err := shared.GetDB()
+	if err != nil {
+		http.Error(w, "Database connection failed", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	backend, err := storage.GetBackend(ctx)
+	if err != nil {
+		http.Error(w, "Storage backend unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	var metadata shared.FileMetadata
+	if err := collection.FindOne(ctx, bson.M{"token": token}).Decode(&metadata); err != nil {
+		http.Error(w, "File not found or expired", http.StatusNotFound)
+		return
+	}
+
+	if metadata.Status != "active" || time.Now().After(metadata.ExpiresAt) {
+		http.Error(w, "File has expired", http.StatusGone)
+		return
+	}
+
+	if metadata.MaxViews != nil && metadata.CurrentViews >= *metadata.MaxViews {
+		http.Error(w, "View limit reached", http.StatusGone)
+		return
+	}
+
+	if !shared.IsArchive(metadata.FileType) {
+		http.Error(w, "Not an archive", http.StatusBadRequest)
+		return
+	}
+
+	if metadata.IsEncrypted {
+		providedTag := r.Header.Get("X-Verifier-Tag")
+		if providedTag == "" || providedTag != metadata.VerifierTag {
+			http.Error(w, "Password required", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	data, err := storage.ReadFileBytes(ctx, backend, metadata)
+	if err != nil {
+		http.Error(w, "Failed to read archive", http.StatusInternalServerError)
+		return
+	}
+
+	entries, err := shared.ListArchiveEntries(metadata.FileType, data)
+	if err != nil {
+		http.Error(w, "Failed to read archive contents", http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"token": token, "entries": entries})
+}
+
+// extractToken pulls the dynamic {token} segment out of
+// /api/view/{token}/list.
+func extractToken(urlPath string) string {
+	trimmed := strings.TrimPrefix(urlPath, "/api/view/")
+	parts := strings.SplitN(trimmed, "/", 2)
+	return parts[0]
+}